@@ -92,7 +92,7 @@ func BenchmarkShURLService_GetAllByUserID(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.GetAllShURLsByUserID(ctx, "user1")
+		service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{})
 	}
 
 	b.StopTimer()
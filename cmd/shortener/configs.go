@@ -14,11 +14,27 @@ func parseAppConfig(configPath string) error {
 	}
 
 	var appConfig struct {
-		ServerAddress   string `json:"server_address"`
-		BaseURL         string `json:"base_url"`
-		FileStoragePath string `json:"file_storage_path"`
-		DatabaseDSN     string `json:"database_dsn"`
-		EnableHTTPS     bool   `json:"enable_https"`
+		ServerAddress    string `json:"server_address"`
+		BaseURL          string `json:"base_url"`
+		FileStoragePath  string `json:"file_storage_path"`
+		DatabaseDSN      string `json:"database_dsn"`
+		EnableHTTPS      bool   `json:"enable_https"`
+		GlobalDedup      bool   `json:"global_dedup"`
+		TokenStrategy    string `json:"token_strategy"`
+		TokenLength      int    `json:"token_length"`
+		TokenAlphabet    string `json:"token_alphabet"`
+		Workers          int    `json:"workers"`
+		DeleteBatchSize  int    `json:"delete_batch_size"`
+		DeleteFlushMS    int    `json:"delete_flush_interval"` // в миллисекундах
+		StripDefaultPort bool   `json:"strip_default_port"`
+		StripFragment    bool   `json:"strip_fragment"`
+		MaxURLLength     int    `json:"max_url_length"`
+		PolicyFile       string `json:"policy_file"`
+		PendingPage      bool   `json:"pending_page"`
+		PendingPageTmpl  string `json:"pending_page_template"`
+		RedirectStatus   int    `json:"redirect_status"`
+		LinkAccessKey    string `json:"link_access_key"`
+		MetricsAddress   string `json:"metrics_address"`
 	}
 
 	err = json.Unmarshal(content, &appConfig)
@@ -31,6 +47,48 @@ func parseAppConfig(configPath string) error {
 	flagDBFilePath = appConfig.FileStoragePath
 	flagDBConnStr = appConfig.DatabaseDSN
 	flagEnableHTTPS = appConfig.EnableHTTPS
+	flagGlobalDedup = appConfig.GlobalDedup
+	flagStripDefaultPort = appConfig.StripDefaultPort
+	flagStripFragment = appConfig.StripFragment
+	flagPendingPage = appConfig.PendingPage
+
+	// Параметры генерации токенов необязательны - при отсутствии в конфиге остаются значения флагов
+	if appConfig.TokenStrategy != "" {
+		flagTokenStrategy = appConfig.TokenStrategy
+	}
+	if appConfig.TokenLength != 0 {
+		flagTokenLength = appConfig.TokenLength
+	}
+	if appConfig.TokenAlphabet != "" {
+		flagTokenAlphabet = appConfig.TokenAlphabet
+	}
+	if appConfig.Workers != 0 {
+		flagWorkers = appConfig.Workers
+	}
+	if appConfig.DeleteBatchSize != 0 {
+		flagDeleteBatchSize = appConfig.DeleteBatchSize
+	}
+	if appConfig.DeleteFlushMS != 0 {
+		flagDeleteFlushInterval = appConfig.DeleteFlushMS
+	}
+	if appConfig.MaxURLLength != 0 {
+		flagMaxURLLength = appConfig.MaxURLLength
+	}
+	if appConfig.PolicyFile != "" {
+		flagPolicyFile = appConfig.PolicyFile
+	}
+	if appConfig.PendingPageTmpl != "" {
+		flagPendingPageTemplate = appConfig.PendingPageTmpl
+	}
+	if appConfig.RedirectStatus != 0 {
+		flagRedirectStatus = appConfig.RedirectStatus
+	}
+	if appConfig.LinkAccessKey != "" {
+		flagLinkAccessKey = appConfig.LinkAccessKey
+	}
+	if appConfig.MetricsAddress != "" {
+		flagMetricsAddr = appConfig.MetricsAddress
+	}
 
 	return nil
 }
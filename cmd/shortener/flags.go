@@ -3,7 +3,10 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/tokengen"
 )
 
 var (
@@ -24,6 +27,54 @@ var (
 
 	// flagConfigPath - путь до конфигурационного файла
 	flagConfigPath string
+
+	// flagGlobalDedup - искать дубли URL среди ссылок всех пользователей (по умолчанию - только среди ссылок создателя)
+	flagGlobalDedup bool
+
+	// flagTokenStrategy - стратегия генерации токенов (random, counter, hash, words)
+	flagTokenStrategy string
+
+	// flagTokenLength - длина (минимальная длина) генерируемых токенов
+	flagTokenLength int
+
+	// flagTokenAlphabet - алфавит случайных токенов (только для стратегии random)
+	flagTokenAlphabet string
+
+	// flagWorkers - количество воркеров сервиса (0 - по количеству доступных процессоров)
+	flagWorkers int
+
+	// flagDeleteBatchSize - количество накопленных токенов, при котором запросы на удаление выполняются пачкой
+	flagDeleteBatchSize int
+
+	// flagDeleteFlushInterval - максимальное время накопления запросов на удаление (в миллисекундах)
+	flagDeleteFlushInterval int
+
+	// flagStripDefaultPort - убирать из длинных URL порт по умолчанию (:80 для http, :443 для https)
+	flagStripDefaultPort bool
+
+	// flagStripFragment - убирать из длинных URL фрагмент (#...)
+	flagStripFragment bool
+
+	// flagMaxURLLength - максимальная длина длинного URL
+	flagMaxURLLength int
+
+	// flagPolicyFile - путь к файлу правил политики адресов назначения ("" - адреса не ограничиваются)
+	flagPolicyFile string
+
+	// flagPendingPage - показывать страницу "ещё не доступна" вместо 404 до начала окна активности ссылки
+	flagPendingPage bool
+
+	// flagPendingPageTemplate - путь к собственному html-шаблону страницы "ещё не доступна" ("" - встроенная страница)
+	flagPendingPageTemplate string
+
+	// flagRedirectStatus - код ответа при переходе по ссылке без собственного кода (301, 302, 307, 308)
+	flagRedirectStatus int
+
+	// flagLinkAccessKey - ключ подписи кук доступа к защищённым паролем ссылкам ("" - случайный ключ при каждом запуске)
+	flagLinkAccessKey string
+
+	// flagMetricsAddr - адрес внутреннего сервера метрик ("" - метрики не отдаются)
+	flagMetricsAddr string
 )
 
 // parseFlags - обрабатывает аргументы командной строки и сохраняет их значения в соответствующих переменных
@@ -34,6 +85,22 @@ func parseFlags() {
 	flag.StringVar(&flagDBConnStr, "d", "", "postgresql connection string (only for postgresql)")
 	flag.BoolVar(&flagEnableHTTPS, "s", false, "enable https")
 	flag.StringVar(&flagConfigPath, "c", "", "path to application config file")
+	flag.BoolVar(&flagGlobalDedup, "global-dedup", false, "detect duplicate URLs across all users instead of per user")
+	flag.StringVar(&flagTokenStrategy, "token-strategy", tokengen.StrategyRandom, "short token generation strategy: random, counter (continues from stored tokens on start), hash or words")
+	flag.IntVar(&flagTokenLength, "token-length", tokengen.DefaultLength, "length of generated tokens (minimal length for counter and hash strategies)")
+	flag.StringVar(&flagTokenAlphabet, "token-alphabet", tokengen.DefaultAlphabet, "alphabet of random tokens (only for random strategy)")
+	flag.IntVar(&flagWorkers, "workers", 0, "number of service workers for reads and for writes (0 - number of CPUs)")
+	flag.IntVar(&flagDeleteBatchSize, "delete-batch-size", 100, "number of accumulated tokens that triggers a bulk delete")
+	flag.IntVar(&flagDeleteFlushInterval, "delete-flush-interval", 500, "max time in milliseconds to accumulate delete requests")
+	flag.BoolVar(&flagStripDefaultPort, "strip-default-port", false, "strip default ports (:80 for http, :443 for https) from long URLs")
+	flag.BoolVar(&flagStripFragment, "strip-fragment", false, "strip fragments (#...) from long URLs")
+	flag.IntVar(&flagMaxURLLength, "max-url-length", 2048, "max length of long URLs")
+	flag.StringVar(&flagPolicyFile, "policy-file", "", "path to destination allow/deny rules file (reloaded on change)")
+	flag.BoolVar(&flagPendingPage, "pending-page", false, "serve a \"not yet available\" page instead of 404 before a link activates")
+	flag.StringVar(&flagPendingPageTemplate, "pending-page-template", "", "path to custom html template of the \"not yet available\" page (implies -pending-page)")
+	flag.IntVar(&flagRedirectStatus, "redirect-status", http.StatusTemporaryRedirect, "default redirect status code for links without their own: 301, 302, 307 or 308")
+	flag.StringVar(&flagLinkAccessKey, "link-access-key", "", "key to sign password protected link access cookies (random on every start if empty; set the same key on all instances)")
+	flag.StringVar(&flagMetricsAddr, "metrics-addr", "", "address of internal metrics server, e.g. localhost:9090 (disabled if empty; do not expose publicly)")
 	flag.Parse()
 
 	flagShortenerRouterAddr = normalizeAddress(flagShortenerRouterAddr)
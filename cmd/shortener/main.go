@@ -5,10 +5,12 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -17,10 +19,12 @@ import (
 	"github.com/JustScorpio/urlshortener/internal/middleware/gzipencoder"
 	"github.com/JustScorpio/urlshortener/internal/middleware/logger"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/policy"
 	"github.com/JustScorpio/urlshortener/internal/repository"
 	"github.com/JustScorpio/urlshortener/internal/repository/jsonfile"
 	"github.com/JustScorpio/urlshortener/internal/repository/postgres"
 	"github.com/JustScorpio/urlshortener/internal/services"
+	"github.com/JustScorpio/urlshortener/internal/tokengen"
 
 	_ "net/http/pprof"
 
@@ -74,13 +78,18 @@ func run() error {
 		flagDBConnStr = envDBConnStr
 	}
 
-	// Инициализация репозиториев с базой данных
+	// Инициализация репозиториев с базой данных (события переходов хранятся в том же хранилище)
 	var repo repository.IRepository[entities.ShURL]
+	var clickRepo repository.IClickRepository
 	var err error
 	if flagDBConnStr != "" {
-		repo, err = postgres.NewPostgresShURLRepository(flagDBConnStr)
+		var postgresRepo *postgres.PostgresShURLRepository
+		postgresRepo, err = postgres.NewPostgresShURLRepository(flagDBConnStr)
+		repo, clickRepo = postgresRepo, postgresRepo
 	} else {
-		repo, err = jsonfile.NewJSONFileShURLRepository(flagDBFilePath)
+		var jsonFileRepo *jsonfile.JSONFileShURLRepository
+		jsonFileRepo, err = jsonfile.NewJSONFileShURLRepository(flagDBFilePath)
+		repo, clickRepo = jsonFileRepo, jsonFileRepo
 	}
 
 	if err != nil {
@@ -89,11 +98,108 @@ func run() error {
 
 	defer repo.CloseConnection()
 
-	// Инициализация сервисов
-	shURLService := services.NewShURLService(repo)
+	//При наличии переменной окружения или наличии флага - дубли URL ищутся среди ссылок всех пользователей
+	if _, hasEnv := os.LookupEnv("GLOBAL_DEDUP"); hasEnv {
+		flagGlobalDedup = true
+	}
 
-	// Инициализация обработчиков
-	shURLHandler := handlers.NewShURLHandler(shURLService, flagRedirectRouterAddr)
+	//Параметры генерации токенов берём из переменных окружения. Иначе - из аргументов
+	if envTokenStrategy, hasEnv := os.LookupEnv("TOKEN_STRATEGY"); hasEnv {
+		flagTokenStrategy = envTokenStrategy
+	}
+	if envTokenLength, hasEnv := os.LookupEnv("TOKEN_LENGTH"); hasEnv {
+		flagTokenLength, err = strconv.Atoi(envTokenLength)
+		if err != nil {
+			return fmt.Errorf("invalid TOKEN_LENGTH: %w", err)
+		}
+	}
+	if envTokenAlphabet, hasEnv := os.LookupEnv("TOKEN_ALPHABET"); hasEnv {
+		flagTokenAlphabet = envTokenAlphabet
+	}
+
+	//Количество воркеров сервиса берём из переменной окружения. Иначе - из аргумента
+	if envWorkers, hasEnv := os.LookupEnv("WORKERS"); hasEnv {
+		flagWorkers, err = strconv.Atoi(envWorkers)
+		if err != nil {
+			return fmt.Errorf("invalid WORKERS: %w", err)
+		}
+	}
+
+	//Параметры пакетного удаления берём из переменных окружения. Иначе - из аргументов
+	if envDeleteBatchSize, hasEnv := os.LookupEnv("DELETE_BATCH_SIZE"); hasEnv {
+		flagDeleteBatchSize, err = strconv.Atoi(envDeleteBatchSize)
+		if err != nil {
+			return fmt.Errorf("invalid DELETE_BATCH_SIZE: %w", err)
+		}
+	}
+	if envDeleteFlushInterval, hasEnv := os.LookupEnv("DELETE_FLUSH_INTERVAL"); hasEnv {
+		flagDeleteFlushInterval, err = strconv.Atoi(envDeleteFlushInterval)
+		if err != nil {
+			return fmt.Errorf("invalid DELETE_FLUSH_INTERVAL: %w", err)
+		}
+	}
+
+	//Параметры нормализации длинных URL берём из переменных окружения. Иначе - из аргументов
+	if _, hasEnv := os.LookupEnv("STRIP_DEFAULT_PORT"); hasEnv {
+		flagStripDefaultPort = true
+	}
+	if _, hasEnv := os.LookupEnv("STRIP_FRAGMENT"); hasEnv {
+		flagStripFragment = true
+	}
+	if envMaxURLLength, hasEnv := os.LookupEnv("MAX_URL_LENGTH"); hasEnv {
+		flagMaxURLLength, err = strconv.Atoi(envMaxURLLength)
+		if err != nil {
+			return fmt.Errorf("invalid MAX_URL_LENGTH: %w", err)
+		}
+	}
+
+	//Файл политики адресов назначения берём из переменной окружения. Иначе - из аргументов
+	if envPolicyFile, hasEnv := os.LookupEnv("POLICY_FILE"); hasEnv {
+		flagPolicyFile = envPolicyFile
+	}
+
+	//Страницу "ещё не доступна" берём из переменных окружения. Иначе - из аргументов
+	if _, hasEnv := os.LookupEnv("PENDING_PAGE"); hasEnv {
+		flagPendingPage = true
+	}
+	if envPendingPageTemplate, hasEnv := os.LookupEnv("PENDING_PAGE_TEMPLATE"); hasEnv {
+		flagPendingPageTemplate = envPendingPageTemplate
+	}
+
+	//Код ответа при переходе по ссылке берём из переменной окружения. Иначе - из аргументов
+	if envRedirectStatus, hasEnv := os.LookupEnv("REDIRECT_STATUS"); hasEnv {
+		flagRedirectStatus, err = strconv.Atoi(envRedirectStatus)
+		if err != nil {
+			return fmt.Errorf("invalid REDIRECT_STATUS: %w", err)
+		}
+	}
+	if !services.IsRedirectStatus(flagRedirectStatus) {
+		return fmt.Errorf("invalid redirect status %d: must be one of 301, 302, 307, 308", flagRedirectStatus)
+	}
+
+	//Ключ подписи кук доступа к защищённым ссылкам берём из переменной окружения. Иначе - из аргументов
+	if envLinkAccessKey, hasEnv := os.LookupEnv("LINK_ACCESS_KEY"); hasEnv {
+		flagLinkAccessKey = envLinkAccessKey
+	}
+
+	tokenGenerator, err := tokengen.New(flagTokenStrategy, flagTokenAlphabet, flagTokenLength)
+	if err != nil {
+		return err
+	}
+
+	// Счётчик продолжает счёт с уже выданных токенов, иначе после перезапуска все новые токены заняты
+	if counter, ok := tokenGenerator.(*tokengen.CounterGenerator); ok {
+		shURLs, err := repo.GetAll(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to seed token counter: %w", err)
+		}
+
+		tokens := make([]string, 0, len(shURLs))
+		for _, shURL := range shURLs {
+			tokens = append(tokens, shURL.Token)
+		}
+		counter.Seed(tokens)
+	}
 
 	//Инициализация логгера
 	zapLogger, err := logger.NewLogger("Info", true)
@@ -102,11 +208,73 @@ func run() error {
 	}
 	defer zapLogger.Sync()
 
+	// Инициализация сервисов
+	serviceOpts := []services.ShURLServiceOption{
+		services.WithClickStore(clickRepo),
+		services.WithGlobalDeduplication(flagGlobalDedup),
+		services.WithTokenGenerator(tokenGenerator),
+		services.WithLogger(zapLogger),
+		services.WithWorkers(flagWorkers),
+		services.WithURLNormalization(services.URLNormalization{
+			StripDefaultPort: flagStripDefaultPort,
+			StripFragment:    flagStripFragment,
+			MaxLength:        flagMaxURLLength,
+		}),
+		services.WithDeletionBatching(flagDeleteBatchSize, time.Duration(flagDeleteFlushInterval)*time.Millisecond),
+	}
+
+	if flagPolicyFile != "" {
+		destinationPolicy, err := policy.NewEngine(flagPolicyFile)
+		if err != nil {
+			return err
+		}
+		serviceOpts = append(serviceOpts, services.WithDestinationPolicy(destinationPolicy, policy.DefaultReloadInterval))
+	}
+
+	shURLService := services.NewShURLService(repo, serviceOpts...)
+
+	//При наличии переменной окружения или наличии флага - запускаем на HTTPS.
+	if _, hasEnv := os.LookupEnv("ENABLE_HTTPS"); hasEnv {
+		flagEnableHTTPS = true
+	}
+
+	// Инициализация обработчиков
+	handlerOpts := []handlers.ShURLHandlerOption{
+		handlers.WithRedirectStatus(flagRedirectStatus),
+		handlers.WithLinkAccessKey([]byte(flagLinkAccessKey)),
+		handlers.WithHTTPS(flagEnableHTTPS),
+	}
+	switch {
+	case flagPendingPageTemplate != "":
+		pendingPage, err := template.ParseFiles(flagPendingPageTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to load pending page template: %w", err)
+		}
+		handlerOpts = append(handlerOpts, handlers.WithPendingPage(pendingPage))
+	case flagPendingPage:
+		handlerOpts = append(handlerOpts, handlers.WithPendingPage(handlers.DefaultPendingPage))
+	}
+
+	shURLHandler := handlers.NewShURLHandler(shURLService, flagRedirectRouterAddr, handlerOpts...)
+
 	// Берём адрес сервера из переменной окружения. Иначе - из аргумента
 	if envServerAddr, hasEnv := os.LookupEnv("SERVER_ADDRESS"); hasEnv {
 		flagShortenerRouterAddr = normalizeAddress(envServerAddr)
 	}
 
+	// Адрес сервера метрик берём из переменной окружения. Иначе - из аргумента
+	if envMetricsAddr, hasEnv := os.LookupEnv("METRICS_ADDRESS"); hasEnv {
+		flagMetricsAddr = envMetricsAddr
+	}
+
+	// Метрики отдаются отдельным внутренним сервером (не на публичных адресах)
+	var metricsServer *http.Server
+	if flagMetricsAddr != "" {
+		metricsRouter := chi.NewRouter()
+		metricsRouter.Get("/metrics", services.MetricsHandler().ServeHTTP)
+		metricsServer = createServer(flagMetricsAddr, metricsRouter, nil)
+	}
+
 	// Проверка подключения к БД
 	pingFunc := func(w http.ResponseWriter, r *http.Request) {
 		if repo.PingDB() {
@@ -116,11 +284,6 @@ func run() error {
 		}
 	}
 
-	//При наличии переменной окружения или наличии флага - запускаем на HTTPS.
-	if _, hasEnv := os.LookupEnv("ENABLE_HTTPS"); hasEnv {
-		flagEnableHTTPS = true
-	}
-
 	//Сертификат для HTTPS (общий при разных flagShortenerRouterAddr и flagRedirectRouterAddr)
 	var tlsConfig *tls.Config
 	if flagEnableHTTPS {
@@ -143,7 +306,11 @@ func run() error {
 		r.Get("/ping", pingFunc)
 		r.Get("/api/user/urls", shURLHandler.GetShURLsByUserID)
 		r.Delete("/api/user/urls", shURLHandler.DeleteMany)
+		r.Patch("/api/user/urls/{token}", shURLHandler.UpdateShURL)
+		r.Get("/api/user/urls/{token}/stats", shURLHandler.GetShURLStats)
 		r.Get("/{token}", shURLHandler.GetFullURL)
+		r.Post("/{token}", shURLHandler.UnlockShURL)
+		r.Get("/{token}/qr", shURLHandler.GetQRCode)
 		r.Post("/api/shorten", shURLHandler.ShortenURL)
 		r.Post("/api/shorten/batch", shURLHandler.ShortenURLsBatch)
 		r.Post("/", shURLHandler.ShortenURL)
@@ -154,11 +321,12 @@ func run() error {
 		}
 
 		// Запуск сервера в горутине
-		serverErr := make(chan error, 1)
+		serverErr := make(chan error, 2)
 		go func() {
 			fmt.Println("Starting server...")
 			serverErr <- runServer(server, tlsConfig)
 		}()
+		runMetricsServer(metricsServer, serverErr)
 
 		// Ожидание сигнала остановки или ошибки сервера
 		select {
@@ -168,7 +336,7 @@ func run() error {
 			fmt.Printf("Server error: %v\n", err)
 		}
 
-		return gracefulShutdown(shURLService, server)
+		return gracefulShutdown(shURLService, server, metricsServer)
 	}
 
 	// Если разные - разные сервера для разных хэндлеров в разных горутинах
@@ -179,7 +347,11 @@ func run() error {
 	redirectRouter.Get("/ping", pingFunc)
 	redirectRouter.Get("/api/user/urls", shURLHandler.GetShURLsByUserID)
 	redirectRouter.Delete("/api/user/urls", shURLHandler.DeleteMany)
+	redirectRouter.Patch("/api/user/urls/{token}", shURLHandler.UpdateShURL)
+	redirectRouter.Get("/api/user/urls/{token}/stats", shURLHandler.GetShURLStats)
 	redirectRouter.Get("/{token}", shURLHandler.GetFullURL)
+	redirectRouter.Post("/{token}", shURLHandler.UnlockShURL)
+	redirectRouter.Get("/{token}/qr", shURLHandler.GetQRCode)
 
 	shortenerRouter := chi.NewRouter()
 	shortenerRouter.Use(logger.LoggingMiddleware(zapLogger))
@@ -194,7 +366,7 @@ func run() error {
 	shortenerServer := createServer(flagShortenerRouterAddr, shortenerRouter, tlsConfig)
 
 	// Запуск серверов в горутинах
-	serverErr := make(chan error, 2)
+	serverErr := make(chan error, 3)
 
 	go func() {
 		fmt.Println("Starting short-to-long server...")
@@ -206,6 +378,8 @@ func run() error {
 		serverErr <- runServer(shortenerServer, tlsConfig)
 	}()
 
+	runMetricsServer(metricsServer, serverErr)
+
 	// Ожидание сигнала остановки или ошибки сервера
 	select {
 	case <-stop:
@@ -214,7 +388,7 @@ func run() error {
 		fmt.Printf("Server error: %v\n", err)
 	}
 
-	return gracefulShutdown(shURLService, redirectServer, shortenerServer)
+	return gracefulShutdown(shURLService, redirectServer, shortenerServer, metricsServer)
 }
 
 // createServer - создает и настраивает HTTP сервер
@@ -243,6 +417,18 @@ func runServer(server *http.Server, tlsConfig *tls.Config) error {
 	return server.ListenAndServe()
 }
 
+// runMetricsServer - запускает в горутине сервер метрик (если он задан), ошибка запуска отправляется в serverErr
+func runMetricsServer(server *http.Server, serverErr chan<- error) {
+	if server == nil {
+		return
+	}
+
+	go func() {
+		fmt.Println("Starting metrics server...")
+		serverErr <- runServer(server, nil)
+	}()
+}
+
 // gracefulShutdown - graceful shutdown приложения
 func gracefulShutdown(service *services.ShURLService, servers ...*http.Server) error {
 	fmt.Println("Starting graceful shutdown...")
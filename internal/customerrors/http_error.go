@@ -8,8 +8,10 @@ import (
 
 // HTTPError - ошибка-ответ на HTTP-запрос
 type HTTPError struct {
-	Err  error
-	Code int
+	Err     error
+	Code    int
+	Reason  string            // машиночитаемая причина ошибки (необязательная), например "unsupported_scheme"
+	Details map[string]string // дополнительные сведения к причине (необязательные), например ID сработавшего правила
 }
 
 // Error - Реализация интерфейса error
@@ -25,6 +27,39 @@ func NewHTTPError(err error, code int) error {
 	}
 }
 
+// NewBadRequestError - создать ошибку с кодом 400
+func NewBadRequestError(err error) error {
+	return &HTTPError{
+		Code: http.StatusBadRequest,
+		Err:  err,
+	}
+}
+
+// NewValidationError - создать ошибку с кодом 400 и машиночитаемой причиной
+func NewValidationError(reason string, err error) error {
+	return &HTTPError{
+		Code:   http.StatusBadRequest,
+		Err:    err,
+		Reason: reason,
+	}
+}
+
+// NewUnauthorizedError - создать ошибку с кодом 401
+func NewUnauthorizedError(err error) error {
+	return &HTTPError{
+		Code: http.StatusUnauthorized,
+		Err:  err,
+	}
+}
+
+// NewForbiddenError - создать ошибку с кодом 403
+func NewForbiddenError(err error) error {
+	return &HTTPError{
+		Code: http.StatusForbidden,
+		Err:  err,
+	}
+}
+
 // NewAlreadyExistsError - создать ошибку с кодом 409
 func NewAlreadyExistsError(err error) error {
 	return &HTTPError{
@@ -57,6 +92,14 @@ func NewNotFoundError(err error) error {
 	}
 }
 
+// NewTooManyRequestsError - создать ошибку с кодом 429
+func NewTooManyRequestsError(err error) error {
+	return &HTTPError{
+		Code: http.StatusTooManyRequests,
+		Err:  err,
+	}
+}
+
 // NewHTTPError - создать ошибку с кодом 503
 func NewServiceUnavailableError(err error) error {
 	return &HTTPError{
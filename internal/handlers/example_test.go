@@ -159,3 +159,30 @@ func ExampleShURLHandler_DeleteMany() {
 	resp := w.Result()
 	defer resp.Body.Close()
 }
+
+// ExampleShURLHandler_UpdateShURL демонстрирует изменение адреса ShURL пользователя.
+func ExampleShURLHandler_UpdateShURL() {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	// Создаем тестовые данные
+	ctx := context.Background()
+	url := dtos.NewShURL{
+		LongURL:   "https://example.com/old",
+		CreatedBy: "user1",
+	}
+	shURL, _ := service.Create(ctx, url)
+
+	body := strings.NewReader(`{"url": "https://example.com/new"}`)
+	req := httptest.NewRequest("PATCH", "/api/user/urls/"+shURL.Token, body)
+	req.Header.Set("Content-Type", "application/json")
+	ctxReq := customcontext.WithUserID(req.Context(), "user1")
+	req = req.WithContext(ctxReq)
+	w := httptest.NewRecorder()
+
+	handler.UpdateShURL(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/services"
+)
+
+// defaultPageSize - количество ссылок на странице списка, если задан курсор, но не задан параметр limit
+const defaultPageSize = 100
+
+// nextCursorHeader - заголовок ответа с курсором следующей страницы списка ссылок (нет заголовка - страница последняя)
+const nextCursorHeader = "X-Next-Cursor"
+
+// Кастомные ошибки разбора параметров списка ссылок
+var (
+	invalidOrderError  = customerrors.NewValidationError(services.URLReasonInvalidSort, errors.New("order must be asc or desc"))
+	invalidCursorError = customerrors.NewValidationError(services.URLReasonInvalidCursor, errors.New("malformed cursor"))
+)
+
+// cursorPayload - содержимое курсора страницы списка ссылок (для клиента курсор непрозрачен)
+type cursorPayload struct {
+	Sort      dtos.ShURLSort `json:"s"`
+	Desc      bool           `json:"d,omitempty"`
+	Token     string         `json:"t"`
+	CreatedAt *time.Time     `json:"c,omitempty"`
+	Clicks    int64          `json:"n,omitempty"`
+}
+
+// listFilter - считать параметры списка ссылок пользователя из запроса:
+//   - tag - метки (повторяются или через запятую; у ссылки должны быть все метки), folder - папка;
+//   - q - подстрока длинного URL или заголовка;
+//   - sort - created (по умолчанию), clicks или alias; order - asc или desc (по умолчанию desc, для alias - asc).
+//     Страницы списка по clicks не согласованы между собой: переходы между запросами сдвигают ссылки (см. dtos.ShURLCursor);
+//   - limit - размер страницы; cursor - значение заголовка X-Next-Cursor предыдущей страницы.
+//     Без limit и cursor возвращаются все ссылки (как до появления постраничного списка), с cursor без limit - defaultPageSize
+func listFilter(r *http.Request) (dtos.ShURLFilter, error) {
+	query := r.URL.Query()
+	filter := dtos.ShURLFilter{
+		Folder: query.Get("folder"),
+		Search: query.Get("q"),
+		Sort:   dtos.ShURLSort(query.Get("sort")),
+	}
+
+	for _, value := range query["tag"] {
+		filter.Tags = append(filter.Tags, strings.Split(value, ",")...)
+	}
+
+	switch query.Get("order") {
+	case "":
+		filter.Desc = filter.Sort != dtos.SortAlias
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return dtos.ShURLFilter{}, invalidOrderError
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		after, err := decodeCursor(value)
+		if err != nil {
+			return dtos.ShURLFilter{}, invalidCursorError
+		}
+		filter.After = after
+		filter.Limit = defaultPageSize
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := services.ParseLimit(value)
+		if err != nil {
+			return dtos.ShURLFilter{}, err
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// encodeCursor - упаковать курсор страницы в строку для заголовка ответа
+func encodeCursor(cursor *dtos.ShURLCursor) string {
+	payload := cursorPayload{Sort: cursor.Sort, Desc: cursor.Desc, Token: cursor.Token, Clicks: cursor.Clicks}
+	if !cursor.CreatedAt.IsZero() {
+		payload.CreatedAt = &cursor.CreatedAt
+	}
+
+	data, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor - распаковать курсор страницы, полученный от клиента
+func decodeCursor(value string) (*dtos.ShURLCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	cursor := &dtos.ShURLCursor{Sort: payload.Sort, Desc: payload.Desc, Token: payload.Token, Clicks: payload.Clicks}
+	if payload.CreatedAt != nil {
+		cursor.CreatedAt = *payload.CreatedAt
+	}
+
+	return cursor, nil
+}
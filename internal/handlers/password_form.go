@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/JustScorpio/urlshortener/internal/middleware/auth"
+)
+
+// WithLinkAccessKey - ключ подписи кук доступа к защищённым паролем ссылкам
+// (по умолчанию - случайный ключ, создаваемый при запуске)
+func WithLinkAccessKey(key []byte) ShURLHandlerOption {
+	return func(h *ShURLHandler) {
+		h.linkAccess = auth.NewLinkAccess(key)
+	}
+}
+
+// passwordFormTemplate - страница ввода пароля защищённой ссылки
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<h1>This link is password protected</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="/{{.Token}}">
+<input type="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// writePasswordForm - отправить страницу ввода пароля (errMessage - сообщение о неудачной попытке)
+func writePasswordForm(w http.ResponseWriter, statusCode int, token, errMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Страница зависит от куки доступа, поэтому не кешируется
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	passwordFormTemplate.Execute(w, struct {
+		Token string
+		Error string
+	}{token, errMessage})
+}
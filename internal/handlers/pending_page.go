@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// DefaultPendingPage - страница "ссылка ещё не доступна" по умолчанию.
+// Шаблон получает NotBefore (time.Time, UTC) - момент, с которого ссылка станет доступна
+var DefaultPendingPage = template.Must(template.New("pending").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Not yet available</title>
+</head>
+<body>
+<h1>This link is not available yet</h1>
+<p>Please come back after <time datetime="{{.NotBefore.Format "2006-01-02T15:04:05Z07:00"}}">{{.NotBefore.Format "2 Jan 2006 15:04 MST"}}</time>.</p>
+</body>
+</html>
+`))
+
+// ShURLHandlerOption - необязательный параметр конфигурации ShURLHandler
+type ShURLHandlerOption func(*ShURLHandler)
+
+// WithPendingPage - показывать страницу page при переходе по ссылке, окно активности которой ещё не началось
+// (по умолчанию - 404, как для несуществующей ссылки)
+func WithPendingPage(page *template.Template) ShURLHandlerOption {
+	return func(h *ShURLHandler) {
+		h.pendingPage = page
+	}
+}
+
+// writePending - ответить на переход по ещё не активной ссылке
+func (h *ShURLHandler) writePending(w http.ResponseWriter, notBefore string) {
+	activatesAt, err := time.Parse(time.RFC3339, notBefore)
+	if h.pendingPage == nil || err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Страница перестанет быть актуальной в момент активации, поэтому не кешируется
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	h.pendingPage.Execute(w, struct {
+		NotBefore time.Time
+	}{activatesAt})
+}
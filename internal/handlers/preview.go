@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// previewSuffix - суффикс токена, по которому вместо перехода показывается предпросмотр ссылки
+const previewSuffix = "+"
+
+// previewTemplate - страница предпросмотра ссылки
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+<p>Short link: <code>{{.ShortURL}}</code></p>
+{{if .OriginalURL}}<p>Leads to: <a href="{{.OriginalURL}}" rel="nofollow noopener noreferrer">{{.OriginalURL}}</a></p>
+{{else}}<p>This link is password protected. The destination is shown after the password is entered.</p>
+{{end}}{{if .CreatedAt}}<p>Created: <time datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "2 Jan 2006 15:04 MST"}}</time></p>
+{{end}}<p><a href="{{.ShortURL}}" rel="nofollow">Continue</a></p>
+</body>
+</html>
+`))
+
+// previewData - сведения о ссылке, показываемые при предпросмотре
+type previewData struct {
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url,omitempty"` // не раскрывается для защищённых паролем ссылок
+	Title             string     `json:"title,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"` // отсутствует для ссылок, созданных до появления поля
+	PasswordProtected bool       `json:"password_protected"`
+}
+
+// previewToken - определить, запрошен ли предпросмотр ссылки, и получить её токен
+func previewToken(r *http.Request, token string) (string, bool) {
+	if strings.HasSuffix(token, previewSuffix) {
+		return strings.TrimSuffix(token, previewSuffix), true
+	}
+
+	return token, r.URL.Query().Get("preview") == "1"
+}
+
+// writePreview - показать сведения о ссылке вместо перехода (JSON при Accept: application/json, иначе html-страница)
+func (h *ShURLHandler) writePreview(w http.ResponseWriter, r *http.Request, shURL *entities.ShURL) {
+	data := previewData{
+		ShortURL:          h.shortURL(shURL.Token),
+		Title:             shURL.Title,
+		PasswordProtected: shURL.PasswordHash != "",
+	}
+
+	// Адрес назначения защищённой ссылки виден только после ввода пароля
+	if !data.PasswordProtected || h.linkAccess.HasAccess(r, shURL.Token) {
+		data.OriginalURL = shURL.LongURL
+	}
+
+	if !shURL.CreatedAt.IsZero() {
+		createdAt := shURL.CreatedAt.UTC()
+		data.CreatedAt = &createdAt
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonData)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	previewTemplate.Execute(w, data)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/qr"
+)
+
+// GetQRCode - получить QR-код укороченной ссылки (PNG или SVG).
+// Параметры запроса: format (png|svg), size (в пикселях), ecc (L|M|Q|H), margin (в модулях)
+func (h *ShURLHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		// разрешаем только Get-запросы
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	//Извлекаем токен из пути (chi.URLParam не используется по той же причине, что и в GetFullURL)
+	token := strings.TrimPrefix(r.URL.Path, "/")
+	token = strings.TrimSuffix(token, "/qr")
+	if token == "" || strings.Contains(token, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseQROptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// QR-код выдаётся только для существующих ссылок (переход при этом не засчитывается)
+	shURL, err := h.service.Get(r.Context(), token)
+	if err != nil {
+		http.Error(w, err.Error(), statusCodeFromError(err))
+		return
+	}
+
+	var image bytes.Buffer
+	if err := qr.Render(&image, h.shortURL(shURL.Token), opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image.Bytes())
+}
+
+// parseQROptions - считать параметры QR-кода из строки запроса (отсутствующие - по умолчанию)
+func parseQROptions(r *http.Request) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" {
+		opts.Format = qr.Format(strings.ToLower(format))
+	}
+
+	if level := query.Get("ecc"); level != "" {
+		opts.Level = level
+	}
+
+	for name, dest := range map[string]*int{"size": &opts.Size, "margin": &opts.Margin} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, err
+		}
+		*dest = n
+	}
+
+	return opts, opts.Validate()
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/services"
+)
+
+// permanentRedirectMaxAge - время кеширования постоянного перехода (301, 308) браузерами и прокси
+const permanentRedirectMaxAge = 24 * time.Hour
+
+// WithRedirectStatus - код ответа при переходе по ссылке, для которой код не задан
+// (301, 302, 307 или 308; по умолчанию - 307)
+func WithRedirectStatus(code int) ShURLHandlerOption {
+	return func(h *ShURLHandler) {
+		h.redirectStatus = code
+	}
+}
+
+// writeRedirect - выполнить переход по ShURL на адрес location (см. ShURLService.Destination)
+// с кодом ссылки (или кодом по умолчанию сервера) и соответствующим ему заголовком Cache-Control
+func (h *ShURLHandler) writeRedirect(w http.ResponseWriter, shURL *entities.ShURL, location string) {
+	code := shURL.RedirectStatus
+	if code == 0 {
+		code = h.redirectStatus
+	}
+
+	w.Header().Set("Cache-Control", redirectCacheControl(code, shURL))
+	w.Header().Add("Location", location)
+	w.WriteHeader(code)
+}
+
+// redirectCacheControl - заголовок Cache-Control для перехода с кодом code.
+// Постоянный переход кешируется, но только для ссылки, которая не может перестать работать сама:
+// закешированный переход обошёл бы проверку пароля, списание переходов и окончание срока действия.
+// Переход с передачей параметров запроса, UTM-метками из Referer, правилами выбора адреса или вариантами A/B-теста
+// тоже не кешируется: адрес назначения зависит от запроса
+func redirectCacheControl(code int, shURL *entities.ShURL) string {
+	isPermanent := code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+	isVolatile := shURL.PasswordHash != "" || shURL.ClicksLeft != nil || shURL.ExpiresAt != nil || shURL.NotAfter != nil ||
+		shURL.Query.Passthrough || services.UsesReferrer(shURL.Query) || len(shURL.Targeting) > 0 || len(shURL.Variants) > 0
+
+	if !isPermanent || isVolatile {
+		return "private, no-cache"
+	}
+
+	return "public, max-age=" + strconv.Itoa(int(permanentRedirectMaxAge.Seconds()))
+}
+
+// redirectRequest - параметры запроса на переход, от которых зависит адрес назначения
+func redirectRequest(r *http.Request) dtos.RedirectRequest {
+	req := dtos.RedirectRequest{
+		Query:  r.URL.Query(),
+		Header: r.Header,
+	}
+
+	// Кука варианта выставляется на путь ссылки, поэтому браузер присылает только куку этой ссылки
+	if cookie, err := r.Cookie(variantCookieName); err == nil {
+		req.Variant = cookie.Value
+	}
+
+	return req
+}
+
+// queryRequest - поля запроса на создание ссылки, задающие правила формирования query-строки адреса назначения
+type queryRequest struct {
+	PassQuery bool `json:"pass_query"` // передавать параметры запроса к короткой ссылке в адрес назначения
+	UTM       struct {
+		Source   string `json:"source"`
+		Medium   string `json:"medium"`
+		Campaign string `json:"campaign"`
+	} `json:"utm"` // шаблоны UTM-меток (допускаются подстановки {token} и {referrer})
+	QueryConflict string `json:"query_conflict"` // keep (по умолчанию) или override
+}
+
+// redirectQuery - правила формирования query-строки из запроса
+func (q queryRequest) redirectQuery() entities.RedirectQuery {
+	return entities.RedirectQuery{
+		Passthrough: q.PassQuery,
+		UTMSource:   q.UTM.Source,
+		UTMMedium:   q.UTM.Medium,
+		UTMCampaign: q.UTM.Campaign,
+		Conflict:    q.QueryConflict,
+	}
+}
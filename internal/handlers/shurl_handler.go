@@ -4,28 +4,64 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customcontext"
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/middleware/auth"
 	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
 	"github.com/JustScorpio/urlshortener/internal/services"
 )
 
 // ShURLHandler - обработчик входящих запросов
 type ShURLHandler struct {
-	service       *services.ShURLService
-	shURLBaseAddr string
+	service        *services.ShURLService
+	shURLBaseAddr  string
+	shortURLScheme string             // схема укороченных ссылок в ответах
+	pendingPage    *template.Template // nil - до начала окна активности ссылка отвечает 404
+	redirectStatus int                // код ответа при переходе по ссылке без собственного кода
+	linkAccess     *auth.LinkAccess   // куки доступа к защищённым паролем ссылкам
 }
 
 // NewShURLHandler - инициализация хэндлера
-func NewShURLHandler(service *services.ShURLService, shURLBaseAddr string) *ShURLHandler {
-	return &ShURLHandler{
-		service:       service,
-		shURLBaseAddr: shURLBaseAddr,
+func NewShURLHandler(service *services.ShURLService, shURLBaseAddr string, opts ...ShURLHandlerOption) *ShURLHandler {
+	handler := &ShURLHandler{
+		service:        service,
+		shURLBaseAddr:  shURLBaseAddr,
+		shortURLScheme: "http",
+		redirectStatus: http.StatusTemporaryRedirect,
 	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	if handler.linkAccess == nil {
+		handler.linkAccess = auth.NewLinkAccess(nil)
+	}
+
+	return handler
+}
+
+// WithHTTPS - отдавать в ответах укороченные ссылки со схемой https (сервер переходов работает по HTTPS)
+func WithHTTPS(enabled bool) ShURLHandlerOption {
+	return func(h *ShURLHandler) {
+		if enabled {
+			h.shortURLScheme = "https"
+		}
+	}
+}
+
+// shortURL - укороченная ссылка для токена
+func (h *ShURLHandler) shortURL(token string) string {
+	return h.shortURLScheme + "://" + h.shURLBaseAddr + "/" + token
 }
 
 // GetFullURL - получить полный адрес
@@ -39,28 +75,130 @@ func (h *ShURLHandler) GetFullURL(w http.ResponseWriter, r *http.Request) {
 	token := strings.TrimPrefix(r.URL.Path, "/")
 	//token := chi.URLParam(r, "token") //Not works. Known chi issue (https://github.com/go-chi/chi/issues/938)
 
+	// Предпросмотр: /{token}+ или /{token}?preview=1
+	token, preview := previewToken(r, token)
+
 	if token == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	// Получение сущности из сервиса
-	shURL, err := h.service.Get(r.Context(), token)
+	shURL, err := h.service.Resolve(r.Context(), token)
 	if err != nil {
-		var statusCode = http.StatusInternalServerError
+		//Если запрашивается shURL c deleted = true, вернётся ошибка с кодом 410, если адрес запрещён политикой - 403
+		h.writeResolveError(w, err)
+		return
+	}
 
-		//Если запрашивается shURL c deleted = true, вернётся ошибка с кодом 410
-		var httpErr *customerrors.HTTPError
-		if errors.As(err, &httpErr) {
-			statusCode = httpErr.Code
+	// Предпросмотр не считается переходом: переходы не списываются и не попадают в статистику
+	if preview {
+		h.writePreview(w, r, shURL)
+		return
+	}
+
+	// Для защищённой паролем ссылки без куки доступа вместо перехода показываем форму ввода пароля
+	if shURL.PasswordHash != "" && !h.linkAccess.HasAccess(r, shURL.Token) {
+		writePasswordForm(w, http.StatusOK, shURL.Token, "")
+		return
+	}
+
+	// Адрес назначения определяется до списания перехода: адрес сработавшего правила может быть запрещён политикой
+	target, err := h.service.Destination(shURL, redirectRequest(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Для ссылки с ограничением переходов списываем переход (410, если переходы закончились)
+	if err := h.service.ConsumeClick(r.Context(), shURL); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	setVariantCookie(w, shURL, target.Variant)
+	h.recordClick(r, shURL, target.Variant)
+
+	h.writeRedirect(w, shURL, target.URL)
+}
+
+// UnlockShURL - проверить пароль защищённой ссылки (отправка формы) и перейти по ней
+func (h *ShURLHandler) UnlockShURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		// разрешаем только POST-запросы
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	shURL, err := h.service.Unlock(r.Context(), token, r.PostFormValue("password"))
+	if err != nil {
+		switch statusCodeFromError(err) {
+		case http.StatusUnauthorized:
+			writePasswordForm(w, http.StatusUnauthorized, token, "Wrong password")
+		case http.StatusTooManyRequests:
+			var httpErr *customerrors.HTTPError
+			if errors.As(err, &httpErr) {
+				w.Header().Set("Retry-After", httpErr.Details["retry_after"])
+			}
+			writeError(w, err)
+		default:
+			h.writeResolveError(w, err)
+		}
+		return
+	}
+
+	target, err := h.service.Destination(shURL, redirectRequest(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.ConsumeClick(r.Context(), shURL); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if shURL.PasswordHash != "" {
+		if err := h.linkAccess.SetCookie(w, shURL.Token); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+	}
+
+	setVariantCookie(w, shURL, target.Variant)
+	h.recordClick(r, shURL, target.Variant)
+
+	// 303: браузер выполнит переход по длинному URL GET-запросом, а не повторит POST с паролем
+	w.Header().Add("Location", target.URL)
+	w.WriteHeader(http.StatusSeeOther)
+}
 
-		http.Error(w, err.Error(), statusCode)
+// writeResolveError - отправить ошибку перехода по ShURL (для ещё не активной ссылки - страницу ожидания или 404)
+func (h *ShURLHandler) writeResolveError(w http.ResponseWriter, err error) {
+	var httpErr *customerrors.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Reason == services.LinkReasonNotYetActive {
+		h.writePending(w, httpErr.Details["not_before"])
 		return
 	}
 
-	w.Header().Add("Location", shURL.LongURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	writeError(w, err)
+}
+
+// recordClick - зарегистрировать переход по ShURL на вариант A/B-теста variant ("" - не по варианту; запись выполняется асинхронно)
+func (h *ShURLHandler) recordClick(r *http.Request, shURL *entities.ShURL, variant string) {
+	h.service.RecordClick(entities.Click{
+		Token:     shURL.Token,
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        truncateIP(r.RemoteAddr),
+		Variant:   variant,
+	})
 }
 
 // ShortenURL - укоротить адрес
@@ -86,11 +224,28 @@ func (h *ShURLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Проверяем и при необходимости ивзлекаем URL из JSON
-	var longURL string
+	var newURL dtos.NewShURL
+	var withQR bool
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "application/json" {
 		var reqData struct {
-			URL string `json:"url"`
+			URL       string     `json:"url"`
+			Alias     string     `json:"alias"`
+			ExpiresAt *time.Time `json:"expires_at"`
+			TTL       int64      `json:"ttl"` // в секундах
+			Password  string     `json:"password"`
+			MaxClicks int        `json:"max_clicks"`
+			NotBefore *time.Time `json:"not_before"`
+			NotAfter  *time.Time `json:"not_after"`
+			Title     string     `json:"title"`
+			Redirect  int        `json:"redirect_status"`
+			queryRequest
+			Targeting []entities.TargetingRule `json:"targeting"`       // правила выбора адреса назначения (по порядку)
+			Variants  []entities.Variant       `json:"variants"`        // варианты A/B-теста
+			Sticky    bool                     `json:"sticky_variants"` // закреплять показанный вариант за посетителем
+			Tags      []string                 `json:"tags"`            // метки для группировки в кабинете пользователя
+			Folder    string                   `json:"folder"`          // папка в кабинете пользователя
+			QR        bool                     `json:"qr"`              // вернуть в ответе ссылку на QR-код
 		}
 
 		if err = json.Unmarshal(body, &reqData); err != nil {
@@ -99,32 +254,50 @@ func (h *ShURLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Конвертируем в строку
-		longURL = reqData.URL
+		newURL = dtos.NewShURL{
+			LongURL:        reqData.URL,
+			Alias:          reqData.Alias,
+			ExpiresAt:      reqData.ExpiresAt,
+			TTL:            time.Duration(reqData.TTL) * time.Second,
+			Password:       reqData.Password,
+			MaxClicks:      reqData.MaxClicks,
+			NotBefore:      reqData.NotBefore,
+			NotAfter:       reqData.NotAfter,
+			Title:          reqData.Title,
+			RedirectStatus: reqData.Redirect,
+			Query:          reqData.redirectQuery(),
+			Targeting:      reqData.Targeting,
+			Variants:       reqData.Variants,
+			StickyVariants: reqData.Sticky,
+			Tags:           reqData.Tags,
+			Folder:         reqData.Folder,
+		}
+		withQR = reqData.QR
 	} else {
-		longURL = string(body)
+		newURL.LongURL = string(body)
 	}
 
-	userID := customcontext.GetUserID(r.Context())
+	newURL.CreatedBy = customcontext.GetUserID(r.Context())
 
 	//Создаём shurl
-	shurl, err := h.service.Create(r.Context(), dtos.NewShURL{
-		LongURL:   longURL,
-		CreatedBy: userID,
-	})
+	shurl, err := h.service.Create(r.Context(), newURL)
 
 	//Определяем статус код
 	statusCode := http.StatusCreated
 	if err != nil {
-		var httpErr *customerrors.HTTPError
-		if errors.As(err, &httpErr) {
-			statusCode = httpErr.Code
+		statusCode = statusCodeFromError(err)
+
+		//Если shurl не создан (например, алиас занят) - возвращаем текст ошибки
+		if shurl == nil {
+			writeError(w, err)
+			return
 		}
 	}
 
 	var responseBody []byte
 	//Если в при создании возникла ошибка, shurl может быть пуст => тело тоже пусто
 	if shurl != nil {
-		shortURL := "http://" + h.shURLBaseAddr + "/" + shurl.Token
+		shortURL := h.shortURL(shurl.Token)
 
 		//Если Header "Accept" == "application/json" - возвращаем ввиде json
 		acceptHeader := r.Header.Get("Accept")
@@ -132,9 +305,13 @@ func (h *ShURLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 			// Конвертируем plain text в JSON
 			var respData struct {
 				Result string `json:"result"`
+				QR     string `json:"qr,omitempty"` // ссылка на QR-код (если запрошена флагом qr)
 			}
 
 			respData.Result = shortURL
+			if withQR {
+				respData.QR = shortURL + "/qr"
+			}
 			jsonData, err := json.Marshal(respData)
 			if err != nil {
 				return
@@ -183,14 +360,32 @@ func (h *ShURLHandler) ShortenURLsBatch(w http.ResponseWriter, r *http.Request)
 	}
 
 	type reqItem struct {
-		ID  string `json:"correlation_id"`
-		URL string `json:"original_url"`
+		ID        string     `json:"correlation_id"`
+		URL       string     `json:"original_url"`
+		Alias     string     `json:"alias"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		TTL       int64      `json:"ttl"` // в секундах
+		Password  string     `json:"password"`
+		MaxClicks int        `json:"max_clicks"`
+		NotBefore *time.Time `json:"not_before"`
+		NotAfter  *time.Time `json:"not_after"`
+		Title     string     `json:"title"`
+		Redirect  int        `json:"redirect_status"`
+		queryRequest
+		Targeting []entities.TargetingRule `json:"targeting"`
+		Variants  []entities.Variant       `json:"variants"`
+		Sticky    bool                     `json:"sticky_variants"`
+		Tags      []string                 `json:"tags"`
+		Folder    string                   `json:"folder"`
 	}
 	var reqData []reqItem
 
 	type respItem struct {
-		ID  string `json:"correlation_id"`
-		URL string `json:"short_url"`
+		ID     string `json:"correlation_id"`
+		URL    string `json:"short_url,omitempty"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+		Reason string `json:"reason,omitempty"`
 	}
 	var respData []respItem
 
@@ -202,21 +397,58 @@ func (h *ShURLHandler) ShortenURLsBatch(w http.ResponseWriter, r *http.Request)
 
 	userID := customcontext.GetUserID(r.Context())
 
+	newURLs := make([]dtos.NewShURL, 0, len(reqData))
 	for _, reqItem := range reqData {
-		longURL := reqItem.URL
-		shurl, err := h.service.Create(r.Context(), dtos.NewShURL{
-			LongURL:   longURL,
-			CreatedBy: userID,
+		newURLs = append(newURLs, dtos.NewShURL{
+			LongURL:        reqItem.URL,
+			CreatedBy:      userID,
+			Alias:          reqItem.Alias,
+			ExpiresAt:      reqItem.ExpiresAt,
+			TTL:            time.Duration(reqItem.TTL) * time.Second,
+			Password:       reqItem.Password,
+			MaxClicks:      reqItem.MaxClicks,
+			NotBefore:      reqItem.NotBefore,
+			NotAfter:       reqItem.NotAfter,
+			Title:          reqItem.Title,
+			RedirectStatus: reqItem.Redirect,
+			Query:          reqItem.redirectQuery(),
+			Targeting:      reqItem.Targeting,
+			Variants:       reqItem.Variants,
+			StickyVariants: reqItem.Sticky,
+			Tags:           reqItem.Tags,
+			Folder:         reqItem.Folder,
 		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	}
+
+	// Некорректные элементы и дубли не мешают созданию остальных - итог сообщается по каждому элементу
+	results, err := h.service.CreateMany(r.Context(), newURLs)
+	if err != nil {
+		http.Error(w, err.Error(), statusCodeFromError(err))
+		return
+	}
+
+	//Если созданы все элементы - 201, иначе 207 (итог каждого элемента - в поле status)
+	statusCode := http.StatusCreated
+	for i, result := range results {
+		item := respItem{
+			ID:     reqData[i].ID,
+			Status: string(result.Status),
 		}
 
-		respData = append(respData, respItem{
-			ID:  reqItem.ID,
-			URL: "http://" + h.shURLBaseAddr + "/" + shurl.Token,
-		})
+		if result.ShURL != nil {
+			item.URL = h.shortURL(result.ShURL.Token)
+		}
+
+		if result.Err != nil {
+			item.Error = result.Err.Error()
+			item.Reason = reasonFromError(result.Err)
+		}
+
+		if result.Status != dtos.BatchItemCreated {
+			statusCode = http.StatusMultiStatus
+		}
+
+		respData = append(respData, item)
 	}
 
 	//Ответ только в "application/json"
@@ -226,11 +458,12 @@ func (h *ShURLHandler) ShortenURLsBatch(w http.ResponseWriter, r *http.Request)
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(statusCode)
 	w.Write(jsonData)
 }
 
-// GetShURLsByUserID - получить полный адрес
+// GetShURLsByUserID - получить страницу ShURL пользователя (параметры отбора, сортировки и страницы - см. listFilter).
+// Ответ - JSON-массив, курсор следующей страницы передаётся в заголовке X-Next-Cursor
 func (h *ShURLHandler) GetShURLsByUserID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		// разрешаем только Get-запросы
@@ -253,28 +486,54 @@ func (h *ShURLHandler) GetShURLsByUserID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	filter, err := listFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
 	// Получение сущностей из сервиса
-	shURLs, err := h.service.GetAllShURLsByUserID(r.Context(), userID)
+	shURLs, next, err := h.service.GetAllShURLsByUserID(r.Context(), userID, filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
+	if next != nil {
+		w.Header().Set(nextCursorHeader, encodeCursor(next))
+	}
+
 	if len(shURLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	type respItem struct {
-		ShortURL    string `json:"short_url"`
-		OriginalURL string `json:"original_url"`
+		ShortURL    string                   `json:"short_url"`
+		OriginalURL string                   `json:"original_url"`
+		NotBefore   *time.Time               `json:"not_before,omitempty"` // окно активности - только для ссылок с расписанием
+		NotAfter    *time.Time               `json:"not_after,omitempty"`
+		Title       string                   `json:"title,omitempty"`
+		Redirect    int                      `json:"redirect_status,omitempty"` // только для ссылок со своим кодом перехода
+		Targeting   []entities.TargetingRule `json:"targeting,omitempty"`
+		Variants    []entities.Variant       `json:"variants,omitempty"`
+		Tags        []string                 `json:"tags,omitempty"`
+		Folder      string                   `json:"folder,omitempty"`
 	}
 	var respData []respItem
 
 	for _, shURL := range shURLs {
 		respData = append(respData, respItem{
-			ShortURL:    "http://" + h.shURLBaseAddr + "/" + shURL.Token,
+			ShortURL:    h.shortURL(shURL.Token),
 			OriginalURL: shURL.LongURL,
+			NotBefore:   shURL.NotBefore,
+			NotAfter:    shURL.NotAfter,
+			Title:       shURL.Title,
+			Redirect:    shURL.RedirectStatus,
+			Targeting:   shURL.Targeting,
+			Variants:    shURL.Variants,
+			Tags:        shURL.Tags,
+			Folder:      shURL.Folder,
 		})
 	}
 
@@ -325,7 +584,7 @@ func (h *ShURLHandler) DeleteMany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Удаление сущностей
+	// Постановка в очередь на удаление (выполняется асинхронно пачкой, поэтому ответ - 202 Accepted)
 	err = h.service.Delete(r.Context(), tokens, userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -335,3 +594,241 @@ func (h *ShURLHandler) DeleteMany(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// UpdateShURL - изменить адрес, на который указывает ShURL пользователя, его метки или папку (PATCH /api/user/urls/{token}).
+// Поля, отсутствующие в запросе, не изменяются
+func (h *ShURLHandler) UpdateShURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		// разрешаем только Patch-запросы
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := customcontext.GetUserID(r.Context())
+	if userID == "" {
+		// UserID в куке пуст
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	//Извлекаем токен из пути
+	token := strings.TrimPrefix(r.URL.Path, "/api/user/urls/")
+	if token == "" || strings.Contains(token, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	//Только Content-Type: JSON
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	//Читаем тело запроса
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var reqData struct {
+		URL    *string   `json:"url"`
+		Tags   *[]string `json:"tags"`   // пустой список - снять все метки
+		Folder *string   `json:"folder"` // "" - убрать из папки
+	}
+
+	if err = json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if reqData.URL == nil && reqData.Tags == nil && reqData.Folder == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	update := dtos.UpdateShURL{
+		Token:       token,
+		UpdatedBy:   userID,
+		KeepLongURL: reqData.URL == nil,
+		Tags:        reqData.Tags,
+		Folder:      reqData.Folder,
+	}
+	if reqData.URL != nil {
+		update.LongURL = *reqData.URL
+	}
+
+	shURL, err := h.service.Update(r.Context(), update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	respData := struct {
+		ShortURL    string   `json:"short_url"`
+		OriginalURL string   `json:"original_url"`
+		Tags        []string `json:"tags,omitempty"`
+		Folder      string   `json:"folder,omitempty"`
+	}{
+		ShortURL:    h.shortURL(shURL.Token),
+		OriginalURL: shURL.LongURL,
+		Tags:        shURL.Tags,
+		Folder:      shURL.Folder,
+	}
+
+	jsonData, err := json.Marshal(respData)
+	if err != nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// GetShURLStats - получить статистику переходов по ShURL пользователя (GET /api/user/urls/{token}/stats)
+func (h *ShURLHandler) GetShURLStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		// разрешаем только Get-запросы
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := customcontext.GetUserID(r.Context())
+	if userID == "" {
+		// UserID в куке пуст
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	//Извлекаем токен из пути (chi.URLParam не используется по той же причине, что и в GetFullURL)
+	token := strings.TrimPrefix(r.URL.Path, "/api/user/urls/")
+	token = strings.TrimSuffix(token, "/stats")
+	if token == "" || strings.Contains(token, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetClickStats(r.Context(), token, userID)
+	if err != nil {
+		http.Error(w, err.Error(), statusCodeFromError(err))
+		return
+	}
+
+	type dailyItem struct {
+		Date   string `json:"date"`
+		Clicks int64  `json:"clicks"`
+	}
+
+	type variantItem struct {
+		Variant string `json:"variant"`
+		Clicks  int64  `json:"clicks"`
+	}
+
+	respData := struct {
+		ShortURL string        `json:"short_url"`
+		Total    int64         `json:"total"`
+		Daily    []dailyItem   `json:"daily"`
+		Variants []variantItem `json:"variants,omitempty"` // только для ссылок с вариантами A/B-теста
+	}{
+		ShortURL: h.shortURL(token),
+		Total:    stats.Total,
+		Daily:    make([]dailyItem, 0, len(stats.Daily)),
+	}
+
+	for _, daily := range stats.Daily {
+		respData.Daily = append(respData.Daily, dailyItem{
+			Date:   daily.Day.Format(time.DateOnly),
+			Clicks: daily.Clicks,
+		})
+	}
+
+	for _, variant := range stats.Variants {
+		respData.Variants = append(respData.Variants, variantItem{
+			Variant: variant.Variant,
+			Clicks:  variant.Clicks,
+		})
+	}
+
+	jsonData, err := json.Marshal(respData)
+	if err != nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// truncateIP - усечь IP-адрес клиента для хранения в статистике (IPv4 до /24, IPv6 до /48)
+func truncateIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+
+	return prefix.Addr().String()
+}
+
+// statusCodeFromError - определить HTTP-код ответа по ошибке сервиса (500, если ошибка не является HTTPError)
+func statusCodeFromError(err error) int {
+	var httpErr *customerrors.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+
+	return http.StatusInternalServerError
+}
+
+// reasonFromError - машиночитаемая причина ошибки сервиса ("" - причина не указана)
+func reasonFromError(err error) string {
+	var httpErr *customerrors.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Reason
+	}
+
+	return ""
+}
+
+// writeError - отправить ошибку сервиса клиенту.
+// Ошибки с машиночитаемой причиной отправляются в JSON ({"error": "...", "reason": "...", <дополнительные сведения>}),
+// остальные - текстом
+func writeError(w http.ResponseWriter, err error) {
+	var httpErr *customerrors.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Reason == "" {
+		http.Error(w, err.Error(), statusCodeFromError(err))
+		return
+	}
+
+	body := make(map[string]string, len(httpErr.Details)+2)
+	for key, value := range httpErr.Details {
+		body[key] = value
+	}
+	body["error"] = err.Error()
+	body["reason"] = httpErr.Reason
+
+	jsonData, _ := json.Marshal(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCodeFromError(err))
+	w.Write(jsonData)
+}
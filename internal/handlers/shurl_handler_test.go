@@ -5,17 +5,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customcontext"
 	"github.com/JustScorpio/urlshortener/internal/handlers"
 	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/policy"
 	"github.com/JustScorpio/urlshortener/internal/repository/inmemory"
 	"github.com/JustScorpio/urlshortener/internal/services"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -83,6 +91,544 @@ func TestShURLHandler_GetFullURL(t *testing.T) {
 
 		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
 	})
+
+	t.Run("one-time link is gone after first redirect", func(t *testing.T) {
+		oneTime, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/once", CreatedBy: "user1", MaxClicks: 1})
+		require.NoError(t, err)
+
+		for _, expected := range []int{http.StatusTemporaryRedirect, http.StatusGone} {
+			req := httptest.NewRequest("GET", "/"+oneTime.Token, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetFullURL(w, req)
+
+			assert.Equal(t, expected, w.Code)
+		}
+	})
+
+	t.Run("scheduled link before activation", func(t *testing.T) {
+		notBefore := time.Now().Add(time.Hour)
+		scheduled, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/launch", CreatedBy: "user1", NotBefore: &notBefore})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/"+scheduled.Token, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+
+		// С настроенной страницей ожидания вместо 404 отдаётся страница
+		w = httptest.NewRecorder()
+		handlers.NewShURLHandler(service, "localhost:8080", handlers.WithPendingPage(handlers.DefaultPendingPage)).GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), notBefore.UTC().Format("2006-01-02T15:04:05Z"))
+	})
+
+	t.Run("blocked destination returns forbidden with rule id", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"id": "no-example", "action": "deny", "host": "example.com"}]}`), 0644))
+		engine, err := policy.NewEngine(path)
+		require.NoError(t, err)
+
+		// Ссылка создана до появления правила: переход проверяется по текущей политике
+		blockingService := services.NewShURLService(mockRepo, services.WithDestinationPolicy(engine, 0))
+		defer blockingService.Shutdown()
+
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		w := httptest.NewRecorder()
+
+		handlers.NewShURLHandler(blockingService, "localhost:8080").GetFullURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Location"))
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, services.URLReasonBlocked, body["reason"])
+		assert.Equal(t, "no-example", body["rule_id"])
+	})
+}
+
+// TestShURLHandler_UnlockShURL - проверка перехода по защищённой паролем ссылке
+func TestShURLHandler_UnlockShURL(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	shURL, err := service.Create(context.Background(), dtos.NewShURL{
+		LongURL:   "https://example.com/draft",
+		CreatedBy: "user1",
+		Password:  "s3cret",
+	})
+	require.NoError(t, err)
+
+	unlock := func(password string) *http.Response {
+		req := httptest.NewRequest("POST", "/"+shURL.Token, strings.NewReader("password="+password))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		handler.UnlockShURL(w, req)
+		return w.Result()
+	}
+
+	t.Run("get serves password form", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+		assert.Empty(t, resp.Header.Get("Location"))
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `action="/`+shURL.Token+`"`)
+	})
+
+	t.Run("wrong password shows form again", func(t *testing.T) {
+		resp := unlock("wrong")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+		assert.Empty(t, resp.Cookies())
+	})
+
+	t.Run("correct password redirects and grants access", func(t *testing.T) {
+		resp := unlock("s3cret")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		assert.Equal(t, shURL.LongURL, resp.Header.Get("Location"))
+		require.Len(t, resp.Cookies(), 1)
+
+		cookie := resp.Cookies()[0]
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, "/"+shURL.Token, cookie.Path)
+
+		// С кукой доступа переход выполняется без формы
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, shURL.LongURL, w.Header().Get("Location"))
+	})
+
+	t.Run("tampered cookie is ignored", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		req.AddCookie(&http.Cookie{Name: "shurl_access", Value: "forged"})
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	t.Run("cookie signed with another key is ignored", func(t *testing.T) {
+		// Кука с правильным содержимым, подписанная ключом куки пользователя, а не ключом кук доступа
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   shURL.Token,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).SignedString([]byte("supersecretkey"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		req.AddCookie(&http.Cookie{Name: "shurl_access", Value: forged})
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	t.Run("cookie is accepted by handler with the same key", func(t *testing.T) {
+		key := []byte("shared-link-access-key")
+		issuer := handlers.NewShURLHandler(service, "localhost:8080", handlers.WithLinkAccessKey(key))
+		verifier := handlers.NewShURLHandler(service, "localhost:8080", handlers.WithLinkAccessKey(key))
+
+		req := httptest.NewRequest("POST", "/"+shURL.Token, strings.NewReader("password=s3cret"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		issuer.UnlockShURL(w, req)
+		require.Len(t, w.Result().Cookies(), 1)
+		cookie := w.Result().Cookies()[0]
+
+		req = httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		verifier.GetFullURL(w, req)
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+		// Обработчик со случайным ключом куку другого экземпляра не принимает
+		w = httptest.NewRecorder()
+		handler.GetFullURL(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// TestShURLHandler_RedirectStatus - проверка кода ответа и Cache-Control при переходе
+func TestShURLHandler_RedirectStatus(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/plain", CreatedBy: "user1"})
+	require.NoError(t, err)
+	permanent, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/moved", CreatedBy: "user1", RedirectStatus: http.StatusPermanentRedirect})
+	require.NoError(t, err)
+	limited, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/limited", CreatedBy: "user1", RedirectStatus: http.StatusMovedPermanently, MaxClicks: 5})
+	require.NoError(t, err)
+	tagged, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/tagged", CreatedBy: "user1", RedirectStatus: http.StatusPermanentRedirect,
+		Query: entities.RedirectQuery{UTMSource: "newsletter", UTMMedium: "from-{referrer}"}})
+	require.NoError(t, err)
+
+	redirect := func(handler *handlers.ShURLHandler, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/"+token, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+		return w
+	}
+
+	t.Run("temporary redirect by default", func(t *testing.T) {
+		w := redirect(handlers.NewShURLHandler(service, "localhost:8080"), plain.Token)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("server default applies to links without their own status", func(t *testing.T) {
+		w := redirect(handlers.NewShURLHandler(service, "localhost:8080", handlers.WithRedirectStatus(http.StatusFound)), plain.Token)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, plain.LongURL, w.Header().Get("Location"))
+	})
+
+	t.Run("link status overrides server default and is cached", func(t *testing.T) {
+		w := redirect(handlers.NewShURLHandler(service, "localhost:8080", handlers.WithRedirectStatus(http.StatusFound)), permanent.Token)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		assert.Equal(t, permanent.LongURL, w.Header().Get("Location"))
+		assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("permanent redirect of limited link is not cached", func(t *testing.T) {
+		w := redirect(handlers.NewShURLHandler(service, "localhost:8080"), limited.Token)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("permanent redirect with referrer in utm is not cached", func(t *testing.T) {
+		w := redirect(handlers.NewShURLHandler(service, "localhost:8080"), tagged.Token)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	})
+}
+
+// TestShURLHandler_RedirectQuery - проверка UTM-меток и передачи параметров запроса при переходе
+func TestShURLHandler_RedirectQuery(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{
+		"url": "https://example.com/landing?ref=site",
+		"pass_query": true,
+		"utm": {"source": "shortener", "campaign": "{token}"},
+		"query_conflict": "override"
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+	w := httptest.NewRecorder()
+
+	handler.ShortenURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	token := strings.TrimPrefix(w.Body.String(), "http://localhost:8080/")
+
+	req = httptest.NewRequest("GET", "/"+token+"?ref=ad&gclid=42", nil)
+	w = httptest.NewRecorder()
+
+	handler.GetFullURL(w, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://example.com/landing?gclid=42&ref=ad&utm_campaign="+token+"&utm_source=shortener", w.Header().Get("Location"))
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+}
+
+// TestShURLHandler_Targeting - проверка перехода по правилам выбора адреса назначения
+func TestShURLHandler_Targeting(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{
+		"url": "https://example.com/app",
+		"targeting": [
+			{"device": "ios", "url": "https://apps.apple.com/app/id1"},
+			{"device": "android", "url": "https://play.google.com/store/apps/details?id=app"}
+		]
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+	w := httptest.NewRecorder()
+
+	handler.ShortenURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	token := strings.TrimPrefix(w.Body.String(), "http://localhost:8080/")
+
+	tests := []struct {
+		userAgent string
+		expected  string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "https://apps.apple.com/app/id1"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8)", "https://play.google.com/store/apps/details?id=app"},
+		{"Mozilla/5.0 (X11; Linux x86_64)", "https://example.com/app"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/"+token, nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, tt.expected, w.Header().Get("Location"))
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	}
+}
+
+// TestShURLHandler_Variants - проверка перехода по ссылке с вариантами A/B-теста
+func TestShURLHandler_Variants(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo, services.WithClickStore(mockRepo))
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{
+		"url": "https://example.com/landing",
+		"variants": [
+			{"id": "red", "url": "https://example.com/red", "weight": 1},
+			{"id": "blue", "url": "https://example.com/blue", "weight": 1}
+		],
+		"sticky_variants": true
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+	w := httptest.NewRecorder()
+
+	handler.ShortenURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	token := strings.TrimPrefix(w.Body.String(), "http://localhost:8080/")
+
+	// Первый переход назначает вариант и закрепляет его кукой
+	req = httptest.NewRequest("GET", "/"+token, nil)
+	w = httptest.NewRecorder()
+	handler.GetFullURL(w, req)
+
+	resp := w.Result()
+	resp.Body.Close()
+	require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1)
+
+	cookie := resp.Cookies()[0]
+	assert.Equal(t, "/"+token, cookie.Path)
+	assert.Equal(t, "https://example.com/"+cookie.Value, resp.Header.Get("Location"))
+
+	// Последующие переходы с кукой ведут на тот же вариант
+	for i := 0; i < 10; i++ {
+		req = httptest.NewRequest("GET", "/"+token, nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, "https://example.com/"+cookie.Value, w.Header().Get("Location"))
+	}
+
+	t.Run("owner stats include variant", func(t *testing.T) {
+		var response struct {
+			Total    int64 `json:"total"`
+			Variants []struct {
+				Variant string `json:"variant"`
+				Clicks  int64  `json:"clicks"`
+			} `json:"variants"`
+		}
+
+		require.Eventually(t, func() bool {
+			req := httptest.NewRequest("GET", "/api/user/urls/"+token+"/stats", nil)
+			req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+			w := httptest.NewRecorder()
+			handler.GetShURLStats(w, req)
+
+			return w.Code == http.StatusOK && json.NewDecoder(w.Body).Decode(&response) == nil && response.Total == 11
+		}, 3*time.Second, 50*time.Millisecond)
+
+		require.Len(t, response.Variants, 1)
+		assert.Equal(t, cookie.Value, response.Variants[0].Variant)
+		assert.Equal(t, int64(11), response.Variants[0].Clicks)
+	})
+}
+
+// TestShURLHandler_Preview - проверка предпросмотра ссылки вместо перехода
+func TestShURLHandler_Preview(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+	ctx := context.Background()
+
+	oneTime, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/report?id=1",
+		CreatedBy: "user1",
+		Title:     "Q3 <report>",
+		MaxClicks: 1,
+	})
+	require.NoError(t, err)
+
+	t.Run("plus suffix renders html page", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+oneTime.Token+"+", nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), "https://example.com/report?id=1")
+		assert.Contains(t, w.Body.String(), "Q3 &lt;report&gt;")
+	})
+
+	t.Run("query parameter with json accept", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+oneTime.Token+"?preview=1", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var body struct {
+			ShortURL    string     `json:"short_url"`
+			OriginalURL string     `json:"original_url"`
+			Title       string     `json:"title"`
+			CreatedAt   *time.Time `json:"created_at"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "http://localhost:8080/"+oneTime.Token, body.ShortURL)
+		assert.Equal(t, oneTime.LongURL, body.OriginalURL)
+		assert.Equal(t, "Q3 <report>", body.Title)
+		require.NotNil(t, body.CreatedAt)
+		assert.WithinDuration(t, time.Now(), *body.CreatedAt, time.Minute)
+	})
+
+	t.Run("preview does not consume clicks", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+oneTime.Token, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	})
+
+	t.Run("protected link hides destination", func(t *testing.T) {
+		protected, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/secret", CreatedBy: "user1", Password: "s3cret"})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/"+protected.Token+"+", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "example.com/secret")
+		assert.Contains(t, w.Body.String(), `"password_protected":true`)
+	})
+
+	t.Run("non-existing token returns not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/nonexistent+", nil)
+		w := httptest.NewRecorder()
+
+		handler.GetFullURL(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// TestShURLHandler_GetQRCode - проверка получения QR-кода укороченной ссылки
+func TestShURLHandler_GetQRCode(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	shURL, err := service.Create(context.Background(), dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	t.Run("png by default", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+shURL.Token+"/qr?size=128", nil)
+		w := httptest.NewRecorder()
+
+		handler.GetQRCode(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+
+		img, err := png.Decode(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 128, img.Bounds().Dx())
+	})
+
+	t.Run("svg with parameters", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/"+shURL.Token+"/qr?format=svg&ecc=H&margin=1&size=512", nil)
+		w := httptest.NewRecorder()
+
+		handler.GetQRCode(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "<svg"))
+		assert.Contains(t, w.Body.String(), `width="512"`)
+	})
+
+	t.Run("invalid parameters return bad request", func(t *testing.T) {
+		for _, query := range []string{"format=gif", "ecc=Z", "size=abc", "size=100000", "margin=-1"} {
+			req := httptest.NewRequest("GET", "/"+shURL.Token+"/qr?"+query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetQRCode(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("non-existing token returns not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/nonexistent/qr", nil)
+		w := httptest.NewRecorder()
+
+		handler.GetQRCode(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
 }
 
 // TestShURLHandler_ShortenURL - проверка укорачивания URL
@@ -109,6 +655,28 @@ func TestShURLHandler_ShortenURL(t *testing.T) {
 		assert.Contains(t, string(bodyBytes), "http://localhost:8080/")
 	})
 
+	t.Run("invalid url returns structured reason", func(t *testing.T) {
+		body := strings.NewReader("javascript:alert(1)")
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", "text/plain")
+		ctx := customcontext.WithUserID(req.Context(), "user1")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.ShortenURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var response map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "unsupported_scheme", response["reason"])
+		assert.NotEmpty(t, response["error"])
+	})
+
 	t.Run("successful creation with JSON content type", func(t *testing.T) {
 		jsonBody := `{"url": "https://example_json.com"}`
 		body := strings.NewReader(jsonBody)
@@ -135,6 +703,23 @@ func TestShURLHandler_ShortenURL(t *testing.T) {
 		assert.Contains(t, response.Result, "http://localhost:8080/")
 	})
 
+	t.Run("qr flag adds qr code link", func(t *testing.T) {
+		body := strings.NewReader(`{"url": "https://example_qr.com", "qr": true}`)
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+
+		handler.ShortenURL(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, response["result"]+"/qr", response["qr"])
+	})
+
 	t.Run("duplicate URL returns conflict status", func(t *testing.T) {
 		// Пытаемся создать дубликат
 		body := strings.NewReader("https://example_text.com")
@@ -152,6 +737,48 @@ func TestShURLHandler_ShortenURL(t *testing.T) {
 		assert.Equal(t, http.StatusConflict, resp2.StatusCode)
 	})
 
+	t.Run("successful creation with alias", func(t *testing.T) {
+		jsonBody := `{"url": "https://example_alias.com", "alias": "my-alias"}`
+		req := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		ctx := customcontext.WithUserID(req.Context(), "user1")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.ShortenURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var response struct {
+			Result string `json:"result"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080/my-alias", response.Result)
+	})
+
+	t.Run("taken alias returns conflict status", func(t *testing.T) {
+		jsonBody := `{"url": "https://example_other.com", "alias": "my-alias"}`
+		req := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		ctx := customcontext.WithUserID(req.Context(), "user2")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.ShortenURL(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(bodyBytes), "alias is already taken")
+	})
+
 	t.Run("empty body returns bad request", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/", nil)
 		ctx := customcontext.WithUserID(req.Context(), "user1")
@@ -212,6 +839,75 @@ func TestShURLHandler_ShortenURLsBatch(t *testing.T) {
 		assert.Len(t, response, 2)
 		assert.Equal(t, "1", response[0]["correlation_id"])
 		assert.Contains(t, response[0]["short_url"], "http://localhost:8080/")
+		assert.Equal(t, "created", response[0]["status"])
+	})
+
+	t.Run("short urls use https when enabled", func(t *testing.T) {
+		httpsHandler := handlers.NewShURLHandler(service, "localhost:8080", handlers.WithHTTPS(true))
+
+		jsonBody, _ := json.Marshal([]map[string]string{{"correlation_id": "1", "original_url": "https://secure.example.com"}})
+		req := httptest.NewRequest("POST", "/api/shorten/batch", bytes.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "secure-user"))
+		w := httptest.NewRecorder()
+
+		httpsHandler.ShortenURLsBatch(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created []map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+		require.Len(t, created, 1)
+		assert.True(t, strings.HasPrefix(created[0]["short_url"], "https://localhost:8080/"), created[0]["short_url"])
+
+		req = httptest.NewRequest("GET", "/api/user/urls", nil)
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "secure-user"))
+		w = httptest.NewRecorder()
+
+		httpsHandler.GetShURLsByUserID(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var listed []map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+		require.Len(t, listed, 1)
+		assert.Equal(t, created[0]["short_url"], listed[0]["short_url"])
+	})
+
+	t.Run("partial success returns multi-status", func(t *testing.T) {
+		batch := []map[string]string{
+			{"correlation_id": "1", "original_url": "https://example3.com"},
+			{"correlation_id": "2", "original_url": "https://example1.com"},
+			{"correlation_id": "3", "original_url": ""},
+		}
+		jsonBody, _ := json.Marshal(batch)
+
+		req := httptest.NewRequest("POST", "/api/shorten/batch", bytes.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		ctx := customcontext.WithUserID(req.Context(), "user1")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		handler.ShortenURLsBatch(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+		var response []map[string]string
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		require.Len(t, response, 3)
+
+		assert.Equal(t, "created", response[0]["status"])
+		assert.Contains(t, response[0]["short_url"], "http://localhost:8080/")
+
+		assert.Equal(t, "existing", response[1]["status"])
+		assert.Contains(t, response[1]["short_url"], "http://localhost:8080/")
+
+		assert.Equal(t, "3", response[2]["correlation_id"])
+		assert.Equal(t, "invalid", response[2]["status"])
+		assert.Empty(t, response[2]["short_url"])
+		assert.NotEmpty(t, response[2]["error"])
 	})
 
 	t.Run("wrong content type returns bad request", func(t *testing.T) {
@@ -282,6 +978,24 @@ func TestShURLHandler_GetShURLsByUserID(t *testing.T) {
 		assert.Len(t, response, 2)
 	})
 
+	t.Run("listing shows schedule", func(t *testing.T) {
+		notBefore := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example3.com", CreatedBy: "user3", NotBefore: &notBefore})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/user/urls", nil)
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user3"))
+		w := httptest.NewRecorder()
+
+		handler.GetShURLsByUserID(w, req)
+
+		var response []map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		require.Len(t, response, 1)
+		assert.Equal(t, "2030-01-01T09:00:00Z", response[0]["not_before"])
+		assert.NotContains(t, response[0], "not_after")
+	})
+
 	t.Run("no user ID returns unauthorized", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/api/user/urls", nil)
 		w := httptest.NewRecorder()
@@ -309,6 +1023,228 @@ func TestShURLHandler_GetShURLsByUserID(t *testing.T) {
 	})
 }
 
+// TestShURLHandler_ListPagination - проверка постраничного списка ShURL пользователя с курсором в заголовке
+func TestShURLHandler_ListPagination(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	ctx := context.Background()
+	for _, alias := range []string{"c-link", "a-link", "b-link"} {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/" + alias, CreatedBy: "user1", Alias: alias})
+		require.NoError(t, err)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/user/urls"+query, nil)
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.GetShURLsByUserID(w, req)
+		return w
+	}
+
+	t.Run("cursor leads to next page", func(t *testing.T) {
+		var tokens []string
+		query := "?sort=alias&limit=2"
+		for {
+			w := list(query)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response []map[string]string
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			for _, item := range response {
+				tokens = append(tokens, strings.TrimPrefix(item["short_url"], "http://localhost:8080/"))
+			}
+
+			cursor := w.Header().Get("X-Next-Cursor")
+			if cursor == "" {
+				break
+			}
+			query = "?sort=alias&limit=2&cursor=" + cursor
+		}
+
+		assert.Equal(t, []string{"a-link", "b-link", "c-link"}, tokens)
+	})
+
+	t.Run("search filters listing", func(t *testing.T) {
+		w := list("?q=B-LINK")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		require.Len(t, response, 1)
+		assert.Equal(t, "https://example.com/b-link", response[0]["original_url"])
+		assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+	})
+
+	t.Run("invalid parameters return bad request", func(t *testing.T) {
+		for _, query := range []string{"?sort=title", "?order=up", "?limit=0", "?limit=5000", "?cursor=not-a-cursor", "?sort=clicks&cursor=eyJzIjoiYWxpYXMiLCJ0IjoiYS1saW5rIn0"} {
+			assert.Equal(t, http.StatusBadRequest, list(query).Code, query)
+		}
+	})
+
+	t.Run("without limit and cursor all links are returned", func(t *testing.T) {
+		for i := 0; i < 101; i++ {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: fmt.Sprintf("https://example.com/page/%d", i), CreatedBy: "user1"})
+			require.NoError(t, err)
+		}
+
+		w := list("")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Len(t, response, 104)
+		assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+
+		// Курсор без limit - страница размера по умолчанию
+		w = list("?limit=1")
+		require.Equal(t, http.StatusOK, w.Code)
+		cursor := w.Header().Get("X-Next-Cursor")
+		require.NotEmpty(t, cursor)
+
+		w = list("?cursor=" + cursor)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Len(t, response, 100)
+		assert.NotEmpty(t, w.Header().Get("X-Next-Cursor"))
+	})
+}
+
+// TestShURLHandler_UpdateShURL - проверка изменения адреса ShURL
+func TestShURLHandler_UpdateShURL(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	// Setup test data
+	ctx := context.Background()
+	shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/old", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	patch := func(userID string, body string) *http.Response {
+		req := httptest.NewRequest("PATCH", "/api/user/urls/"+shURL.Token, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req = req.WithContext(customcontext.WithUserID(req.Context(), userID))
+		}
+		w := httptest.NewRecorder()
+		handler.UpdateShURL(w, req)
+		return w.Result()
+	}
+
+	t.Run("owner updates long URL", func(t *testing.T) {
+		resp := patch("user1", `{"url": "https://example.com/new"}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var response map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "http://localhost:8080/"+shURL.Token, response["short_url"])
+		assert.Equal(t, "https://example.com/new", response["original_url"])
+
+		// Токен прежний, редирект ведёт на новый адрес
+		req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+		w := httptest.NewRecorder()
+		handler.GetFullURL(w, req)
+		assert.Equal(t, "https://example.com/new", w.Result().Header.Get("Location"))
+	})
+
+	t.Run("another user is forbidden", func(t *testing.T) {
+		resp := patch("user2", `{"url": "https://example.com/evil"}`)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("no user ID returns unauthorized", func(t *testing.T) {
+		resp := patch("", `{"url": "https://example.com/new"}`)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("empty url returns bad request", func(t *testing.T) {
+		resp := patch("user1", `{"url": ""}`)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+// TestShURLHandler_Tags - проверка меток и папок: создание, отбор в списке ссылок и изменение
+func TestShURLHandler_Tags(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	shorten := func(body string) string {
+		req := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.ShortenURL(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return strings.TrimPrefix(response["result"], "http://localhost:8080/")
+	}
+
+	list := func(query string) []map[string]any {
+		req := httptest.NewRequest("GET", "/api/user/urls"+query, nil)
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.GetShURLsByUserID(w, req)
+		if w.Code == http.StatusNoContent {
+			return nil
+		}
+
+		var response []map[string]any
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+
+	token := shorten(`{"url": "https://example.com/report", "tags": ["Work", "q3"], "folder": "Reports"}`)
+	shorten(`{"url": "https://example.com/blog", "tags": ["blog"]}`)
+
+	t.Run("listing is filtered by tag", func(t *testing.T) {
+		response := list("?tag=work")
+		require.Len(t, response, 1)
+		assert.Equal(t, []any{"q3", "work"}, response[0]["tags"])
+		assert.Equal(t, "Reports", response[0]["folder"])
+
+		assert.Len(t, list("?tag=work,q3"), 1)
+		assert.Len(t, list("?tag=work&tag=blog"), 0)
+		assert.Len(t, list("?folder=Reports"), 1)
+		assert.Len(t, list(""), 2)
+	})
+
+	t.Run("tags are edited with PATCH", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/api/user/urls/"+token, strings.NewReader(`{"tags": ["blog"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.UpdateShURL(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]any
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, "https://example.com/report", response["original_url"])
+		assert.Equal(t, []any{"blog"}, response["tags"])
+
+		assert.Len(t, list("?tag=blog"), 2)
+		assert.Len(t, list("?tag=work"), 0)
+	})
+
+	t.Run("empty PATCH returns bad request", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/api/user/urls/"+token, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(customcontext.WithUserID(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.UpdateShURL(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 // TestShURLHandler_DeleteMany - проверка удаления ShURL
 func TestShURLHandler_DeleteMany(t *testing.T) {
 	mockRepo := inmemory.NewInMemoryRepository()
@@ -386,3 +1322,66 @@ func TestShURLHandler_DeleteMany(t *testing.T) {
 		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
 	})
 }
+
+// TestShURLHandler_GetShURLStats - проверка получения статистики переходов по ShURL
+func TestShURLHandler_GetShURLStats(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo, services.WithClickStore(mockRepo))
+	handler := handlers.NewShURLHandler(service, "localhost:8080")
+
+	// Setup test data
+	ctx := context.Background()
+	shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	// Переход по ссылке
+	req := httptest.NewRequest("GET", "/"+shURL.Token, nil)
+	req.RemoteAddr = "203.0.113.57:54321"
+	req.Header.Set("Referer", "https://referrer.example")
+	w := httptest.NewRecorder()
+	handler.GetFullURL(w, req)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	getStats := func(userID string) *http.Response {
+		req := httptest.NewRequest("GET", "/api/user/urls/"+shURL.Token+"/stats", nil)
+		req = req.WithContext(customcontext.WithUserID(req.Context(), userID))
+		w := httptest.NewRecorder()
+		handler.GetShURLStats(w, req)
+		return w.Result()
+	}
+
+	t.Run("owner gets stats", func(t *testing.T) {
+		var response struct {
+			Total int64 `json:"total"`
+			Daily []struct {
+				Date   string `json:"date"`
+				Clicks int64  `json:"clicks"`
+			} `json:"daily"`
+		}
+
+		// События записываются асинхронно
+		require.Eventually(t, func() bool {
+			resp := getStats("user1")
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return false
+			}
+			return json.NewDecoder(resp.Body).Decode(&response) == nil && response.Total == 1
+		}, 3*time.Second, 50*time.Millisecond)
+
+		require.Len(t, response.Daily, 1)
+		assert.Equal(t, int64(1), response.Daily[0].Clicks)
+	})
+
+	t.Run("another user is forbidden", func(t *testing.T) {
+		resp := getStats("user2")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("no user ID returns unauthorized", func(t *testing.T) {
+		resp := getStats("")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
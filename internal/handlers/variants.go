@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// variantCookieName - имя куки с ID варианта A/B-теста, показанного посетителю
+const variantCookieName = "shurl_variant"
+
+// variantCookieLifetime - время, в течение которого за посетителем закреплён показанный вариант
+const variantCookieLifetime = 30 * 24 * time.Hour
+
+// setVariantCookie - закрепить показанный вариант за посетителем (только для ссылок с закреплением вариантов).
+// Кука выставляется на путь ссылки: варианты разных ссылок закрепляются независимо
+func setVariantCookie(w http.ResponseWriter, shURL *entities.ShURL, variant string) {
+	if !shURL.StickyVariants || variant == "" {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName,
+		Value:    variant,
+		Path:     "/" + shURL.Token,
+		MaxAge:   int(variantCookieLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
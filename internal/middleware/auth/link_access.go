@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rand"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// Имя куки с доступом к защищённой паролем ссылке (кука ограничена путём ссылки)
+	linkAccessCookieName = "shurl_access"
+	// LinkAccessLifeTime - время, в течение которого по защищённой ссылке можно переходить без повторного ввода пароля
+	LinkAccessLifeTime = time.Minute * 10
+	// Длина случайного ключа подписи кук доступа
+	linkAccessKeyLength = 32
+)
+
+// LinkAccess - выдача и проверка кук доступа к защищённым паролем ссылкам.
+// Куки подписываются собственным ключом, не связанным с ключом куки пользователя
+type LinkAccess struct {
+	key []byte
+}
+
+// NewLinkAccess - инициализация LinkAccess с ключом подписи key. При пустом key используется случайный ключ:
+// выданные куки перестают действовать после перезапуска и не подходят другим экземплярам сервиса
+func NewLinkAccess(key []byte) *LinkAccess {
+	if len(key) == 0 {
+		key = make([]byte, linkAccessKeyLength)
+		// Начиная с Go 1.24 rand.Read не возвращает ошибок
+		rand.Read(key)
+	}
+
+	return &LinkAccess{key: key}
+}
+
+// SetCookie - выдать подписанную куку доступа к защищённой паролем ссылке token
+func (a *LinkAccess) SetCookie(w http.ResponseWriter, token string) error {
+	expiresAt := time.Now().Add(LinkAccessLifeTime)
+
+	// Подписываем токен ссылки: кука одной ссылки не подходит для другой, даже если переставить её путь
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   token,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}).SignedString(a.key)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkAccessCookieName,
+		Value:    signed,
+		Path:     "/" + token,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// HasAccess - есть ли в запросе действующая кука доступа к защищённой паролем ссылке token
+func (a *LinkAccess) HasAccess(r *http.Request, token string) bool {
+	cookie, err := r.Cookie(linkAccessCookieName)
+	if err != nil {
+		return false
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	parsed, err := jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return a.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	return err == nil && parsed.Valid && claims.Subject == token
+}
@@ -0,0 +1,21 @@
+// Пакет dtos содержит структуры используемые для переноса данных между разными частями приложения
+package dtos
+
+import "github.com/JustScorpio/urlshortener/internal/models/entities"
+
+// BatchItemStatus - итог обработки элемента пачки
+type BatchItemStatus string
+
+// Итоги обработки элемента пачки BatchItemStatus
+const (
+	BatchItemCreated  BatchItemStatus = "created"  // создан новый ShURL
+	BatchItemExisting BatchItemStatus = "existing" // URL уже сокращён - возвращён существующий ShURL
+	BatchItemInvalid  BatchItemStatus = "invalid"  // элемент отклонён, причина - в Err
+)
+
+// BatchItemResult - результат создания одного элемента пачки ShURL
+type BatchItemResult struct {
+	ShURL  *entities.ShURL // nil для отклонённых элементов
+	Status BatchItemStatus
+	Err    error
+}
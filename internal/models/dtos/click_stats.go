@@ -0,0 +1,24 @@
+// Пакет dtos содержит структуры используемые для переноса данных между разными частями приложения
+package dtos
+
+import "time"
+
+// ClickStats - статистика переходов по ShURL
+type ClickStats struct {
+	Total int64
+	Daily []DailyClicks // по возрастанию даты
+	// Variants - количество переходов по вариантам A/B-теста (по возрастанию ID варианта; nil - переходов по вариантам не было)
+	Variants []VariantClicks
+}
+
+// VariantClicks - количество переходов, при которых был показан вариант A/B-теста
+type VariantClicks struct {
+	Variant string
+	Clicks  int64
+}
+
+// DailyClicks - количество переходов за сутки (UTC)
+type DailyClicks struct {
+	Day    time.Time
+	Clicks int64
+}
@@ -1,8 +1,43 @@
 // Пакет dtos содержит структуры используемые для переноса данных между разными частями приложения
 package dtos
 
+import (
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
 // NewShURL - dto для новых создаваемых shURL
 type NewShURL struct {
 	LongURL   string
 	CreatedBy string
+	// Title - заголовок ссылки для страницы предпросмотра (необязательный)
+	Title string
+	// RedirectStatus - код ответа при переходе по ссылке (301, 302, 307, 308; 0 - код по умолчанию сервера)
+	RedirectStatus int
+	// Alias - желаемый токен (пустая строка - токен будет сгенерирован)
+	Alias string
+	// ExpiresAt - момент истечения срока жизни ссылки (взаимоисключающе с TTL)
+	ExpiresAt *time.Time
+	// TTL - время жизни ссылки с момента создания (взаимоисключающе с ExpiresAt)
+	TTL time.Duration
+	// Password - пароль для перехода по ссылке (пустая строка - ссылка не защищена)
+	Password string
+	// MaxClicks - максимальное количество переходов по ссылке (0 - без ограничения)
+	MaxClicks int
+	// NotBefore, NotAfter - окно, в течение которого по ссылке можно перейти (nil - без ограничения с этой стороны)
+	NotBefore *time.Time
+	NotAfter  *time.Time
+	// Query - UTM-метки и передача параметров запроса в адрес назначения
+	Query entities.RedirectQuery
+	// Targeting - правила выбора адреса назначения по параметрам запроса (проверяются по порядку)
+	Targeting []entities.TargetingRule
+	// Variants - варианты A/B-теста (выбираются случайно по весу, если не сработало ни одно правило Targeting)
+	Variants []entities.Variant
+	// StickyVariants - закреплять показанный вариант за посетителем
+	StickyVariants bool
+	// Tags - метки ссылки для группировки в кабинете пользователя
+	Tags []string
+	// Folder - папка ссылки в кабинете пользователя ("" - вне папок)
+	Folder string
 }
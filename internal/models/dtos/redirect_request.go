@@ -0,0 +1,24 @@
+package dtos
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RedirectRequest - параметры запроса на переход по ShURL, от которых зависит адрес назначения
+type RedirectRequest struct {
+	// Query - параметры запроса к короткой ссылке
+	Query url.Values
+	// Header - заголовки запроса (User-Agent, Accept-Language, Referer и др.)
+	Header http.Header
+	// Variant - ID варианта A/B-теста, ранее показанного посетителю ("" - посетитель ещё не видел вариантов)
+	Variant string
+}
+
+// RedirectTarget - адрес назначения перехода по ShURL
+type RedirectTarget struct {
+	// URL - адрес, на который выполняется переход
+	URL string
+	// Variant - ID выбранного варианта A/B-теста ("" - переход не по варианту)
+	Variant string
+}
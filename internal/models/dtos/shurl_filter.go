@@ -0,0 +1,44 @@
+// Пакет dtos содержит структуры используемые для переноса данных между разными частями приложения
+package dtos
+
+import "time"
+
+// ShURLSort - поле сортировки списка ShURL пользователя
+type ShURLSort string
+
+// Поля сортировки ShURLSort (при равных значениях ссылки упорядочиваются по токену)
+const (
+	SortCreated ShURLSort = "created" // момент создания (ссылки, созданные до появления поля, - в начале)
+	SortClicks  ShURLSort = "clicks"  // количество переходов (постраничный обход не стабилен - см. ShURLCursor)
+	SortAlias   ShURLSort = "alias"   // токен (алиас) ссылки
+)
+
+// ShURLFilter - условия отбора и порядок ShURL пользователя (нулевое значение - все ссылки по возрастанию момента создания)
+type ShURLFilter struct {
+	// Tags - метки, каждая из которых должна быть у ссылки
+	Tags []string
+	// Folder - папка ссылки ("" - ссылки из любых папок)
+	Folder string
+	// Search - подстрока длинного URL или заголовка без учёта регистра ("" - без поиска)
+	Search string
+	// Sort - поле сортировки ("" - SortCreated)
+	Sort ShURLSort
+	// Desc - сортировка по убыванию
+	Desc bool
+	// Limit - максимальное количество ссылок (0 - без ограничения)
+	Limit int
+	// After - позиция, после которой начинается страница (nil - с начала списка)
+	After *ShURLCursor
+}
+
+// ShURLCursor - позиция в списке ShURL пользователя: значения ключа сортировки последней ссылки страницы.
+// Действительна только для того же поля и направления сортировки.
+// Для SortClicks курсор хранит количество переходов на момент выдачи страницы: если между запросами страниц
+// по ссылкам переходят, их позиция в списке меняется, и ссылка может быть пропущена или показана повторно
+type ShURLCursor struct {
+	Sort      ShURLSort
+	Desc      bool
+	Token     string
+	CreatedAt time.Time // для SortCreated
+	Clicks    int64     // для SortClicks
+}
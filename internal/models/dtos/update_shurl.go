@@ -0,0 +1,15 @@
+// Пакет dtos содержит структуры используемые для переноса данных между разными частями приложения
+package dtos
+
+// UpdateShURL - dto для изменения существующего shURL его создателем
+type UpdateShURL struct {
+	Token     string
+	UpdatedBy string
+	LongURL   string
+	// KeepLongURL - не изменять длинный URL (LongURL игнорируется)
+	KeepLongURL bool
+	// Tags - новые метки ссылки (nil - не изменять, пустой список - снять все метки)
+	Tags *[]string
+	// Folder - новая папка ссылки (nil - не изменять, "" - убрать из папки)
+	Folder *string
+}
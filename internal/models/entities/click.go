@@ -0,0 +1,14 @@
+// Пакет entities содержит структуры реализующие сущности доменной модели приложения
+package entities
+
+import "time"
+
+// Click - событие перехода по укороченной ссылке
+type Click struct {
+	Token     string
+	Timestamp time.Time
+	Referrer  string
+	UserAgent string
+	IP        string // усечённый IP-адрес (последний октет IPv4 / хвост IPv6 обнулены)
+	Variant   string // ID показанного варианта A/B-теста ("" - у ссылки нет вариантов)
+}
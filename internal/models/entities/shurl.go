@@ -1,14 +1,61 @@
 // Пакет entities содержит структуры реализующие сущности доменной модели приложения
 package entities
 
+import "time"
+
 // ShURL - укороченная ссылка
 type ShURL struct {
-	Token     string
-	LongURL   string
-	CreatedBy string
+	Token          string
+	LongURL        string
+	CreatedBy      string
+	CreatedAt      time.Time  // момент создания (нулевое значение - ссылка создана до появления поля)
+	Title          string     // заголовок, заданный создателем (показывается на странице предпросмотра)
+	RedirectStatus int        // код ответа при переходе (301, 302, 307, 308; 0 - код по умолчанию сервера)
+	ExpiresAt      *time.Time // nil - ссылка бессрочная
+	PasswordHash   string     // bcrypt-хеш пароля ("" - ссылка не защищена паролем)
+	ClicksLeft     *int       // оставшееся количество переходов (nil - без ограничения)
+	NotBefore      *time.Time // момент начала окна активности (nil - ссылка активна сразу)
+	NotAfter       *time.Time // момент окончания окна активности (nil - без окончания)
+	Query          RedirectQuery
+	Targeting      []TargetingRule // правила выбора адреса назначения (проверяются по порядку, без совпадений - LongURL)
+	Variants       []Variant       // варианты A/B-теста, из которых выбирается адрес назначения, если не сработало ни одно правило
+	StickyVariants bool            // закреплять показанный вариант за посетителем (через куку)
+	Tags           []string        // метки для группировки ссылок в кабинете пользователя (по возрастанию, без повторов)
+	Folder         string          // папка ссылки в кабинете пользователя ("" - вне папок)
+}
+
+// RedirectQuery - правила формирования query-строки адреса назначения при переходе
+// (нулевое значение - адрес назначения не изменяется)
+type RedirectQuery struct {
+	Passthrough bool   // добавлять параметры запроса к короткой ссылке
+	UTMSource   string // шаблон значения utm_source ("" - параметр не добавляется)
+	UTMMedium   string // шаблон значения utm_medium
+	UTMCampaign string // шаблон значения utm_campaign
+	Conflict    string // при совпадении имён параметров: keep ("") - оставить имеющийся, override - заменить
 }
 
 // GetID - реализация интерфейса IEntity
 func (su ShURL) GetID() string {
 	return su.Token
 }
+
+// TargetingRule - правило выбора адреса назначения по параметрам запроса на переход.
+// Правило срабатывает, если выполнены все заданные в нём условия (незаданные условия не проверяются).
+// Хранится в БД в виде JSON
+type TargetingRule struct {
+	Device      string `json:"device,omitempty"`       // семейство User-Agent: ios, android, windows, macos, linux
+	Language    string `json:"language,omitempty"`     // язык из Accept-Language ("en" совпадает и с "en-US")
+	Header      string `json:"header,omitempty"`       // имя заголовка запроса
+	HeaderValue string `json:"header_value,omitempty"` // значение заголовка без учёта регистра ("" - достаточно наличия заголовка)
+	From        string `json:"from,omitempty"`         // начало окна времени суток (ЧЧ:ММ, включительно)
+	To          string `json:"to,omitempty"`           // конец окна времени суток (ЧЧ:ММ, не включительно; окно может переходить через полночь)
+	TimeZone    string `json:"time_zone,omitempty"`    // часовой пояс окна времени суток (IANA, "" - UTC)
+	URL         string `json:"url"`                    // адрес назначения при срабатывании правила
+}
+
+// Variant - вариант адреса назначения A/B-теста. Хранится в БД в виде JSON
+type Variant struct {
+	ID     string `json:"id"`     // идентификатор варианта (попадает в статистику переходов)
+	URL    string `json:"url"`    // адрес назначения
+	Weight int    `json:"weight"` // вес варианта: вероятность показа пропорциональна весу (0 - вариант не показывается)
+}
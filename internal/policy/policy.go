@@ -0,0 +1,215 @@
+// Пакет policy содержит политику допустимых адресов назначения укороченных ссылок
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Action - действие правила политики
+type Action string
+
+// Действия правил политики Action
+const (
+	ActionAllow Action = "allow"
+	ActionDeny  Action = "deny"
+)
+
+// DefaultRuleID - ID, под которым сообщается решение по умолчанию (ни одно правило не сработало)
+const DefaultRuleID = "default"
+
+// DefaultReloadInterval - периодичность проверки файла правил на изменения по умолчанию
+const DefaultReloadInterval = 5 * time.Second
+
+// Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
+var (
+	errInvalidAction  = errors.New("rule action must be \"allow\" or \"deny\"")
+	errEmptyRuleID    = errors.New("rule id is empty")
+	errDuplicateRule  = errors.New("duplicate rule id")
+	errInvalidMatcher = errors.New("rule must have exactly one of \"host\" or \"regex\"")
+)
+
+// Rule - правило политики. Правило сопоставляется с хостом адреса назначения одним из способов:
+// host - точное имя ("example.com") или все поддомены ("*.example.com", само example.com не подходит);
+// regex - регулярное выражение (для совпадения с именем целиком используйте ^ и $)
+type Rule struct {
+	ID     string `json:"id"`
+	Action Action `json:"action"`
+	Host   string `json:"host,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Config - содержимое файла правил
+type Config struct {
+	Default Action `json:"default"` // действие, если ни одно правило не сработало (по умолчанию - allow)
+	Rules   []Rule `json:"rules"`   // правила проверяются по порядку, срабатывает первое совпавшее
+}
+
+// Decision - решение политики по адресу назначения
+type Decision struct {
+	Allowed bool
+	RuleID  string // ID сработавшего правила или DefaultRuleID
+}
+
+// Engine - политика адресов назначения, загружаемая из файла.
+// Правила подменяются атомарно, поэтому Evaluate можно вызывать параллельно с перезагрузкой
+type Engine struct {
+	path   string
+	config atomic.Pointer[Config]
+
+	mu      sync.Mutex // защищает modTime и size при перезагрузке
+	modTime time.Time
+	size    int64
+}
+
+// NewEngine - загрузить политику из файла path
+func NewEngine(path string) (*Engine, error) {
+	engine := &Engine{path: path}
+	if _, err := engine.Reload(); err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+// Evaluate - проверить хост адреса назначения (ожидается хост в нижнем регистре, IDN - в punycode)
+func (e *Engine) Evaluate(host string) Decision {
+	config := e.config.Load()
+	host = strings.TrimSuffix(host, ".")
+
+	for _, rule := range config.Rules {
+		if rule.matches(host) {
+			return Decision{Allowed: rule.Action == ActionAllow, RuleID: rule.ID}
+		}
+	}
+
+	return Decision{Allowed: config.Default != ActionDeny, RuleID: DefaultRuleID}
+}
+
+// Reload - перечитать файл правил, если он изменился с последней загрузки.
+// При ошибке разбора действуют ранее загруженные правила. Возвращает true, если правила обновлены
+func (e *Engine) Reload() (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return false, err
+	}
+
+	if info.ModTime().Equal(e.modTime) && info.Size() == e.size && e.config.Load() != nil {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return false, err
+	}
+
+	config, err := Parse(data)
+	if err != nil {
+		return false, fmt.Errorf("policy file %s: %w", e.path, err)
+	}
+
+	e.config.Store(config)
+	e.modTime = info.ModTime()
+	e.size = info.Size()
+
+	return true, nil
+}
+
+// Watch - перечитывать файл правил каждые interval до закрытия stop
+func (e *Engine) Watch(stop <-chan struct{}, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := e.Reload()
+			if err != nil {
+				logger.Error("failed to reload destination policy, keeping previous rules", zap.Error(err))
+				continue
+			}
+
+			if reloaded {
+				logger.Info("destination policy reloaded", zap.String("path", e.path))
+			}
+		}
+	}
+}
+
+// Parse - разобрать и проверить содержимое файла правил
+func Parse(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	switch config.Default {
+	case "":
+		config.Default = ActionAllow
+	case ActionAllow, ActionDeny:
+	default:
+		return nil, fmt.Errorf("default: %w", errInvalidAction)
+	}
+
+	ids := make(map[string]struct{}, len(config.Rules))
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule #%d: %w", i, errEmptyRuleID)
+		}
+
+		if _, exists := ids[rule.ID]; exists {
+			return nil, fmt.Errorf("rule %q: %w", rule.ID, errDuplicateRule)
+		}
+		ids[rule.ID] = struct{}{}
+
+		if rule.Action != ActionAllow && rule.Action != ActionDeny {
+			return nil, fmt.Errorf("rule %q: %w", rule.ID, errInvalidAction)
+		}
+
+		if (rule.Host == "") == (rule.Regex == "") {
+			return nil, fmt.Errorf("rule %q: %w", rule.ID, errInvalidMatcher)
+		}
+
+		rule.Host = strings.TrimSuffix(strings.ToLower(rule.Host), ".")
+
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.ID, err)
+			}
+			rule.re = re
+		}
+	}
+
+	return &config, nil
+}
+
+// matches - подходит ли хост под правило
+func (r *Rule) matches(host string) bool {
+	switch {
+	case r.re != nil:
+		return r.re.MatchString(host)
+	case strings.HasPrefix(r.Host, "*."):
+		return strings.HasSuffix(host, r.Host[1:])
+	default:
+		return host == r.Host
+	}
+}
@@ -0,0 +1,109 @@
+// Пакет policy_test содержит тесты политики адресов назначения
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRules - записать файл правил и сдвинуть время его изменения, чтобы перезагрузка не зависела от точности mtime
+func writeRules(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// TestEngine_Evaluate - проверка сопоставления хостов с правилами
+func TestEngine_Evaluate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writeRules(t, path, `{
+		"rules": [
+			{"id": "partner-ok", "action": "allow", "host": "safe.evil.com"},
+			{"id": "evil-subdomains", "action": "deny", "host": "*.evil.com"},
+			{"id": "evil-apex", "action": "deny", "host": "Evil.COM"},
+			{"id": "risky-tld", "action": "deny", "regex": "\\.(zip|mov)$"}
+		]
+	}`, time.Now())
+
+	engine, err := policy.NewEngine(path)
+	require.NoError(t, err)
+
+	tests := map[string]policy.Decision{
+		"example.com":     {Allowed: true, RuleID: policy.DefaultRuleID},
+		"evil.com":        {Allowed: false, RuleID: "evil-apex"},
+		"evil.com.":       {Allowed: false, RuleID: "evil-apex"},
+		"a.b.evil.com":    {Allowed: false, RuleID: "evil-subdomains"},
+		"notevil.com":     {Allowed: true, RuleID: policy.DefaultRuleID},
+		"safe.evil.com":   {Allowed: true, RuleID: "partner-ok"},
+		"download.zip":    {Allowed: false, RuleID: "risky-tld"},
+		"zip.example.com": {Allowed: true, RuleID: policy.DefaultRuleID},
+	}
+	for host, expected := range tests {
+		assert.Equal(t, expected, engine.Evaluate(host), host)
+	}
+}
+
+// TestEngine_DefaultDeny - режим allowlist: разрешены только хосты из правил
+func TestEngine_DefaultDeny(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writeRules(t, path, `{"default": "deny", "rules": [{"id": "corp", "action": "allow", "host": "*.corp.example"}]}`, time.Now())
+
+	engine, err := policy.NewEngine(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, policy.Decision{Allowed: true, RuleID: "corp"}, engine.Evaluate("wiki.corp.example"))
+	assert.Equal(t, policy.Decision{Allowed: false, RuleID: policy.DefaultRuleID}, engine.Evaluate("example.com"))
+}
+
+// TestEngine_Reload - правила перечитываются при изменении файла, некорректный файл не заменяет действующие правила
+func TestEngine_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	writeRules(t, path, `{"rules": []}`, start)
+
+	engine, err := policy.NewEngine(path)
+	require.NoError(t, err)
+	assert.True(t, engine.Evaluate("evil.com").Allowed)
+
+	reloaded, err := engine.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file must not be reparsed")
+
+	writeRules(t, path, `{"rules": [{"id": "evil", "action": "deny", "host": "evil.com"}]}`, start.Add(time.Minute))
+	reloaded, err = engine.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, policy.Decision{Allowed: false, RuleID: "evil"}, engine.Evaluate("evil.com"))
+
+	writeRules(t, path, `{"rules": [{"id": "broken", "action": "deny", "regex": "("}]}`, start.Add(2*time.Minute))
+	_, err = engine.Reload()
+	require.Error(t, err)
+	assert.Equal(t, "evil", engine.Evaluate("evil.com").RuleID, "previous rules must stay in effect")
+}
+
+// TestParse - проверка валидации файла правил
+func TestParse(t *testing.T) {
+	invalid := map[string]string{
+		"malformed json":  `{"rules": [`,
+		"unknown default": `{"default": "block"}`,
+		"unknown action":  `{"rules": [{"id": "a", "action": "block", "host": "a.com"}]}`,
+		"missing id":      `{"rules": [{"action": "deny", "host": "a.com"}]}`,
+		"duplicate id":    `{"rules": [{"id": "a", "action": "deny", "host": "a.com"}, {"id": "a", "action": "deny", "host": "b.com"}]}`,
+		"no matcher":      `{"rules": [{"id": "a", "action": "deny"}]}`,
+		"both matchers":   `{"rules": [{"id": "a", "action": "deny", "host": "a.com", "regex": "a"}]}`,
+		"invalid regexp":  `{"rules": [{"id": "a", "action": "deny", "regex": "("}]}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := policy.Parse([]byte(content))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Пакет qr содержит генерацию QR-кодов в форматах PNG и SVG (без внешних зависимостей на этапе выполнения)
+package qr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Format - формат изображения QR-кода
+type Format string
+
+// Форматы изображения Format
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+// Значения параметров по умолчанию и их допустимые пределы
+const (
+	DefaultSize   = 256
+	MinSize       = 32
+	MaxSize       = 2048
+	DefaultMargin = 4 // ширина "тихой зоны" в модулях, рекомендованная стандартом
+	MaxMargin     = 32
+	DefaultLevel  = "M"
+)
+
+// levels - уровни коррекции ошибок (доля восстанавливаемых данных: L - 7%, M - 15%, Q - 25%, H - 30%)
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
+var (
+	errInvalidFormat = errors.New("format must be png or svg")
+	errInvalidLevel  = errors.New("error correction level must be one of L, M, Q, H")
+	errInvalidSize   = fmt.Errorf("size must be between %d and %d", MinSize, MaxSize)
+	errInvalidMargin = fmt.Errorf("margin must be between 0 and %d", MaxMargin)
+)
+
+// Options - параметры изображения QR-кода
+type Options struct {
+	Format Format
+	Size   int    // ширина и высота изображения в пикселях
+	Level  string // уровень коррекции ошибок: L, M, Q или H
+	Margin int    // ширина "тихой зоны" вокруг кода в модулях
+}
+
+// DefaultOptions - параметры по умолчанию
+func DefaultOptions() Options {
+	return Options{
+		Format: FormatPNG,
+		Size:   DefaultSize,
+		Level:  DefaultLevel,
+		Margin: DefaultMargin,
+	}
+}
+
+// Validate - проверить параметры
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return errInvalidFormat
+	}
+
+	if _, ok := levels[strings.ToUpper(o.Level)]; !ok {
+		return errInvalidLevel
+	}
+
+	if o.Size < MinSize || o.Size > MaxSize {
+		return errInvalidSize
+	}
+
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return errInvalidMargin
+	}
+
+	return nil
+}
+
+// ContentType - MIME-тип изображения
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// Render - закодировать content в QR-код и записать изображение в w
+func Render(w io.Writer, content string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	code, err := qrcode.New(content, levels[strings.ToUpper(opts.Level)])
+	if err != nil {
+		return err
+	}
+
+	// Встроенная "тихая зона" библиотеки фиксирована (4 модуля), поэтому рисуем свою
+	code.DisableBorder = true
+	modules := withMargin(code.Bitmap(), opts.Margin)
+
+	if opts.Format == FormatSVG {
+		return writeSVG(w, modules, opts.Size)
+	}
+
+	return png.Encode(w, rasterize(modules, opts.Size))
+}
+
+// withMargin - добавить вокруг матрицы модулей пустую рамку шириной margin
+func withMargin(bitmap [][]bool, margin int) [][]bool {
+	n := len(bitmap) + 2*margin
+
+	modules := make([][]bool, n)
+	for y := range modules {
+		modules[y] = make([]bool, n)
+		if y >= margin && y < n-margin {
+			copy(modules[y][margin:], bitmap[y-margin])
+		}
+	}
+
+	return modules
+}
+
+// rasterize - отрисовать модули в изображение size x size.
+// Модули масштабируются целым числом пикселей (чтобы не размывать края) и центрируются;
+// если size меньше количества модулей, изображение увеличивается до одного пикселя на модуль
+func rasterize(modules [][]bool, size int) image.Image {
+	n := len(modules)
+	scale := max(size/n, 1)
+	size = max(size, n*scale)
+	offset := (size - n*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// writeSVG - записать модули в виде SVG (один путь из горизонтальных отрезков, координаты - в модулях)
+func writeSVG(w io.Writer, modules [][]bool, size int) error {
+	n := len(modules)
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprint(bw, `<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+
+	for y, row := range modules {
+		for x := 0; x < n; x++ {
+			if !row[x] {
+				continue
+			}
+
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(bw, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	fmt.Fprint(bw, `"/></svg>`)
+
+	return bw.Flush()
+}
@@ -0,0 +1,79 @@
+// Пакет qr_test содержит тесты генерации QR-кодов
+package qr_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"regexp"
+	"testing"
+
+	"github.com/JustScorpio/urlshortener/internal/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "http://localhost:8080/abcdefgh"
+
+// isDark - тёмный ли пиксель
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// TestRender_PNG - проверка размера изображения и "тихой зоны"
+func TestRender_PNG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Size = 300
+	opts.Margin = 2
+
+	// 14 байт - версия 1 (21 модуль) при уровне M
+	var buf bytes.Buffer
+	require.NoError(t, qr.Render(&buf, "http://a.io/xy", opts))
+
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// 21 модуль + рамка 2x2 = 25 модулей по 12 пикселей
+	assert.False(t, isDark(img.At(23, 23)), "margin must be white")
+	assert.True(t, isDark(img.At(24, 24)), "finder pattern must start right after the margin")
+}
+
+// TestRender_SVG - проверка SVG-изображения
+func TestRender_SVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Format = qr.FormatSVG
+	opts.Level = "h"
+	opts.Margin = 0
+
+	var buf bytes.Buffer
+	require.NoError(t, qr.Render(&buf, content, opts))
+
+	svg := buf.String()
+	assert.Regexp(t, regexp.MustCompile(`^<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256" viewBox="0 0 \d+ \d+"`), svg)
+	assert.Contains(t, svg, `d="M0 0h7v1h-7z`, "finder pattern must start in the corner without margin")
+	assert.Equal(t, "image/svg+xml", opts.ContentType())
+}
+
+// TestOptions_Validate - проверка параметров изображения
+func TestOptions_Validate(t *testing.T) {
+	invalid := map[string]func(*qr.Options){
+		"unknown format":  func(o *qr.Options) { o.Format = "gif" },
+		"unknown level":   func(o *qr.Options) { o.Level = "X" },
+		"too small":       func(o *qr.Options) { o.Size = qr.MinSize - 1 },
+		"too large":       func(o *qr.Options) { o.Size = qr.MaxSize + 1 },
+		"negative margin": func(o *qr.Options) { o.Margin = -1 },
+		"too wide margin": func(o *qr.Options) { o.Margin = qr.MaxMargin + 1 },
+	}
+	for name, modify := range invalid {
+		t.Run(name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			modify(&opts)
+
+			assert.Error(t, opts.Validate())
+			assert.Error(t, qr.Render(&bytes.Buffer{}, content, opts))
+		})
+	}
+}
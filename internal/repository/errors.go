@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AlreadyExistsError - ошибка создания сущности с уже занятым ID (в т.ч. ID удалённой сущности)
+type AlreadyExistsError struct {
+	ID string
+}
+
+// Error - текст ошибки
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("entity %q already exists", e.ID)
+}
+
+// NewAlreadyExistsError - создать ошибку коллизии ID
+func NewAlreadyExistsError(id string) *AlreadyExistsError {
+	return &AlreadyExistsError{ID: id}
+}
+
+// IsAlreadyExists - является ли ошибка (или одна из обёрнутых в неё) коллизией ID
+func IsAlreadyExists(err error) bool {
+	var existsErr *AlreadyExistsError
+	return errors.As(err, &existsErr)
+}
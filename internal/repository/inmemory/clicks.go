@@ -0,0 +1,41 @@
+package inmemory
+
+import (
+	"context"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+)
+
+// AddClicks - сохранить пачку событий переходов
+func (m *InMemoryRepository) AddClicks(ctx context.Context, clicks []entities.Click) error {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+
+	for _, click := range clicks {
+		m.clicks[click.Token] = append(m.clicks[click.Token], click)
+	}
+	return nil
+}
+
+// GetClickStats - получить статистику переходов по токену
+func (m *InMemoryRepository) GetClickStats(ctx context.Context, token string) (*dtos.ClickStats, error) {
+	m.clicksMu.RLock()
+	defer m.clicksMu.RUnlock()
+
+	return repository.AggregateClicks(m.clicks[token]), nil
+}
+
+// clickCounts - количество переходов по токенам
+func (m *InMemoryRepository) clickCounts() map[string]int64 {
+	m.clicksMu.RLock()
+	defer m.clicksMu.RUnlock()
+
+	counts := make(map[string]int64, len(m.clicks))
+	for token, clicks := range m.clicks {
+		counts[token] = int64(len(clicks))
+	}
+
+	return counts
+}
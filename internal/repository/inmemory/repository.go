@@ -4,17 +4,20 @@ package inmemory
 import (
 	"context"
 	"errors"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
 )
 
 // Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
 var (
-	errNotFound      = customerrors.NewNotFoundError(errors.New("not found"))
-	errAlreadyExists = errors.New("already exists")
-	errGone          = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errNotFound = customerrors.NewNotFoundError(errors.New("not found"))
+	errGone     = customerrors.NewGoneError(errors.New("shurl has been deleted"))
 )
 
 // InMemoryRepository - репозиторий
@@ -23,6 +26,13 @@ type InMemoryRepository struct {
 	shURLs        map[string]entities.ShURL
 	deletedShURLs map[string]entities.ShURL
 	mu            sync.RWMutex
+
+	// Вторичные индексы по неудалённым ShURL: значение -> множество токенов
+	byLongURL map[string]map[string]struct{}
+	byUserID  map[string]map[string]struct{}
+
+	clicks   map[string][]entities.Click // события переходов по токенам
+	clicksMu sync.RWMutex
 }
 
 // NewInMemoryRepository - инициализация репозитория
@@ -30,6 +40,9 @@ func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
 		shURLs:        make(map[string]entities.ShURL),
 		deletedShURLs: make(map[string]entities.ShURL),
+		byLongURL:     make(map[string]map[string]struct{}),
+		byUserID:      make(map[string]map[string]struct{}),
+		clicks:        make(map[string][]entities.Click),
 	}
 }
 
@@ -62,16 +75,89 @@ func (m *InMemoryRepository) Get(ctx context.Context, token string) (*entities.S
 	return nil, errNotFound
 }
 
+// GetWithDeleted - получить ShURL по токену, в том числе удалённую
+func (m *InMemoryRepository) GetWithDeleted(ctx context.Context, token string) (*entities.ShURL, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if shURL, exists := m.shURLs[token]; exists {
+		return &shURL, false, nil
+	}
+
+	if shURL, exists := m.deletedShURLs[token]; exists {
+		return &shURL, true, nil
+	}
+
+	return nil, false, errNotFound
+}
+
+// GetByLongURL - получить неудалённые ShURL, указывающие на длинный URL
+func (m *InMemoryRepository) GetByLongURL(ctx context.Context, longURL string) ([]entities.ShURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.collect(m.byLongURL[longURL]), nil
+}
+
+// GetByUserID - получить страницу неудалённых ShURL, созданных пользователем и удовлетворяющих фильтру
+func (m *InMemoryRepository) GetByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]entities.ShURL, *dtos.ShURLCursor, error) {
+	m.mu.RLock()
+	result := m.collect(m.byUserID[userID])
+	m.mu.RUnlock()
+
+	result = slices.DeleteFunc(result, func(shURL entities.ShURL) bool {
+		return !repository.MatchesFilter(&shURL, filter)
+	})
+
+	var clicks map[string]int64
+	if filter.Sort == dtos.SortClicks {
+		clicks = m.clickCounts()
+	}
+
+	page, next := repository.PageShURLs(result, filter, clicks)
+	return page, next, nil
+}
+
 // Create - создать ShURL
 func (m *InMemoryRepository) Create(ctx context.Context, shURL *entities.ShURL) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if _, exists := m.shURLs[shURL.Token]; exists {
-		return errAlreadyExists
+		return repository.NewAlreadyExistsError(shURL.Token)
+	}
+
+	// Токены удалённых ShURL повторно не выдаются
+	if _, exists := m.deletedShURLs[shURL.Token]; exists {
+		return repository.NewAlreadyExistsError(shURL.Token)
 	}
 
 	m.shURLs[shURL.Token] = *shURL
+	m.index(*shURL)
+	return nil
+}
+
+// CreateMany - создать ShURL атомарно: при коллизии любого токена не создаётся ни один
+func (m *InMemoryRepository) CreateMany(ctx context.Context, shURLs []entities.ShURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := make(map[string]struct{}, len(shURLs))
+	for _, shURL := range shURLs {
+		_, inBatch := tokens[shURL.Token]
+		_, exists := m.shURLs[shURL.Token]
+		_, deleted := m.deletedShURLs[shURL.Token]
+		if inBatch || exists || deleted {
+			return repository.NewAlreadyExistsError(shURL.Token)
+		}
+		tokens[shURL.Token] = struct{}{}
+	}
+
+	for _, shURL := range shURLs {
+		m.shURLs[shURL.Token] = shURL
+		m.index(shURL)
+	}
+
 	return nil
 }
 
@@ -80,14 +166,47 @@ func (m *InMemoryRepository) Update(ctx context.Context, shURL *entities.ShURL)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.shURLs[shURL.Token]; exists {
-		m.shURLs[shURL.Token] = *shURL
+	if old, exists := m.shURLs[shURL.Token]; exists {
+		updated := *shURL
+		updated.ClicksLeft = old.ClicksLeft
+
+		m.unindex(old)
+		m.shURLs[shURL.Token] = updated
+		m.index(updated)
 		return nil
 	} else {
 		return errNotFound
 	}
 }
 
+// ConsumeClick - списать переход (проверка и списание выполняются под одной блокировкой)
+func (m *InMemoryRepository) ConsumeClick(ctx context.Context, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shURL, exists := m.shURLs[token]
+	if !exists {
+		if _, deleted := m.deletedShURLs[token]; deleted {
+			return false, errGone
+		}
+		return false, errNotFound
+	}
+
+	if shURL.ClicksLeft == nil {
+		return true, nil
+	}
+
+	if *shURL.ClicksLeft <= 0 {
+		return false, nil
+	}
+
+	// Новый указатель: ранее выданные копии ShURL не должны меняться
+	left := *shURL.ClicksLeft - 1
+	shURL.ClicksLeft = &left
+	m.shURLs[token] = shURL
+	return true, nil
+}
+
 // Delete - удалить ShURL
 func (m *InMemoryRepository) Delete(ctx context.Context, tokens []string, userID string) error {
 	m.mu.Lock()
@@ -95,13 +214,75 @@ func (m *InMemoryRepository) Delete(ctx context.Context, tokens []string, userID
 
 	for _, token := range tokens {
 		if shURL, exists := m.shURLs[token]; exists && shURL.CreatedBy == userID {
-			m.deletedShURLs[token] = shURL
-			delete(m.shURLs, token)
+			m.markDeleted(shURL)
 		}
 	}
 	return nil
 }
 
+// DeleteExpired - пометить удалёнными ShURL, срок жизни которых истёк к моменту now
+func (m *InMemoryRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, shURL := range m.shURLs {
+		if shURL.ExpiresAt != nil && !now.Before(*shURL.ExpiresAt) {
+			m.markDeleted(shURL)
+		}
+	}
+	return nil
+}
+
+// markDeleted - перенести ShURL в удалённые (вызывается под m.mu)
+func (m *InMemoryRepository) markDeleted(shURL entities.ShURL) {
+	m.unindex(shURL)
+	m.deletedShURLs[shURL.Token] = shURL
+	delete(m.shURLs, shURL.Token)
+}
+
+// index - добавить ShURL во вторичные индексы (вызывается под m.mu)
+func (m *InMemoryRepository) index(shURL entities.ShURL) {
+	addToIndex(m.byLongURL, shURL.LongURL, shURL.Token)
+	addToIndex(m.byUserID, shURL.CreatedBy, shURL.Token)
+}
+
+// unindex - убрать ShURL из вторичных индексов (вызывается под m.mu)
+func (m *InMemoryRepository) unindex(shURL entities.ShURL) {
+	removeFromIndex(m.byLongURL, shURL.LongURL, shURL.Token)
+	removeFromIndex(m.byUserID, shURL.CreatedBy, shURL.Token)
+}
+
+// collect - получить ShURL по множеству токенов из индекса (вызывается под m.mu)
+func (m *InMemoryRepository) collect(tokens map[string]struct{}) []entities.ShURL {
+	result := make([]entities.ShURL, 0, len(tokens))
+	for token := range tokens {
+		result = append(result, m.shURLs[token])
+	}
+
+	return result
+}
+
+// addToIndex - добавить токен в индекс по ключу
+func addToIndex(index map[string]map[string]struct{}, key, token string) {
+	tokens, exists := index[key]
+	if !exists {
+		tokens = make(map[string]struct{})
+		index[key] = tokens
+	}
+
+	tokens[token] = struct{}{}
+}
+
+// removeFromIndex - убрать токен из индекса по ключу
+func removeFromIndex(index map[string]map[string]struct{}, key, token string) {
+	tokens := index[key]
+	delete(tokens, token)
+
+	if len(tokens) == 0 {
+		delete(index, key)
+	}
+}
+
 // CloseConnection - закрыть соединение с базой данных
 func (m *InMemoryRepository) CloseConnection() {
 }
@@ -0,0 +1,104 @@
+package jsonfile
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+)
+
+// clicksFilePath - путь до файла событий переходов рядом с файлом БД (data/shortener.json -> data/shortener_clicks.jsonl)
+func clicksFilePath(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "_clicks.jsonl"
+}
+
+// AddClicks - сохранить пачку событий переходов
+// События дописываются в конец файла в формате JSON Lines, поэтому файл не перезаписывается целиком
+func (r *JSONFileShURLRepository) AddClicks(ctx context.Context, clicks []entities.Click) error {
+	r.clicksMu.Lock()
+	defer r.clicksMu.Unlock()
+
+	file, err := os.OpenFile(r.clicksFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, click := range clicks {
+		if err := encoder.Encode(click); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// GetClickStats - получить статистику переходов по токену
+func (r *JSONFileShURLRepository) GetClickStats(ctx context.Context, token string) (*dtos.ClickStats, error) {
+	var clicks []entities.Click
+	err := r.readClicks(ctx, func(click entities.Click) {
+		if click.Token == token {
+			clicks = append(clicks, click)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.AggregateClicks(clicks), nil
+}
+
+// clickCounts - количество переходов по токенам
+func (r *JSONFileShURLRepository) clickCounts(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := r.readClicks(ctx, func(click entities.Click) {
+		counts[click.Token]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// readClicks - считать все события переходов из файла (отсутствующий файл - событий нет)
+func (r *JSONFileShURLRepository) readClicks(ctx context.Context, fn func(entities.Click)) error {
+	r.clicksMu.Lock()
+	defer r.clicksMu.Unlock()
+
+	file, err := os.Open(r.clicksFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		// Проверяем, не отменен ли контекст
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var click entities.Click
+		if err := decoder.Decode(&click); err != nil {
+			return fmt.Errorf("ошибка парсинга JSON: %w", err)
+		}
+
+		fn(click)
+	}
+
+	return nil
+}
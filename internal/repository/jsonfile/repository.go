@@ -8,22 +8,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
 	_ "modernc.org/sqlite"
 )
 
 // Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
 var (
-	errNotFound      = errors.New("not found")
-	errAlreadyExists = errors.New("already exists")
-	errGone          = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errNotFound = customerrors.NewNotFoundError(errors.New("not found"))
+	errGone     = customerrors.NewGoneError(errors.New("shurl has been deleted"))
 )
 
 // JSONFileShURLRepository - репозиторий
 type JSONFileShURLRepository struct {
 	filePath string
+	mu       sync.RWMutex // запись перезаписывает файл целиком, поэтому чтение не должно пересекаться с записью
+
+	clicksFilePath string     // файл событий переходов (JSON Lines)
+	clicksMu       sync.Mutex // AddClicks вызывается из фоновой горутины параллельно с чтением статистики
 }
 
 // ShURLEntry - расширение ShURL с информацией о том удалена ли сущность
@@ -52,13 +59,23 @@ func NewJSONFileShURLRepository(filePath string) (*JSONFileShURLRepository, erro
 		}
 	}
 
-	return &JSONFileShURLRepository{filePath: filePath}, nil
+	return &JSONFileShURLRepository{
+		filePath:       filePath,
+		clicksFilePath: clicksFilePath(filePath),
+	}, nil
 }
 
 // GetAllEntries - получить все сущности из json-файла
 // В отличие от GetAll возвращает []ShURLEntry которые содержат метку удаления deleted
 func (r *JSONFileShURLRepository) GetAllEntries(ctx context.Context) ([]ShURLEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.readEntries(ctx)
+}
 
+// readEntries - считать все записи из json-файла (вызывающий должен удерживать mu)
+func (r *JSONFileShURLRepository) readEntries(ctx context.Context) ([]ShURLEntry, error) {
 	var file, err = os.ReadFile(r.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
@@ -77,6 +94,42 @@ func (r *JSONFileShURLRepository) GetAllEntries(ctx context.Context) ([]ShURLEnt
 	return entries, nil
 }
 
+// writeEntries - атомарно перезаписать json-файл (вызывающий должен удерживать mu).
+// Данные пишутся во временный файл рядом с основным и подменяют его переименованием,
+// поэтому сбой посреди записи не оставляет файл обрезанным
+func (r *JSONFileShURLRepository) writeEntries(entries []ShURLEntry) error {
+	jsonShurls, err := json.MarshalIndent(entries, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.filePath), filepath.Base(r.filePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // после успешного переименования файла уже нет и ошибка игнорируется
+
+	if _, err := tmp.Write(jsonShurls); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.filePath)
+}
+
 // GetAll - получить все ShURL
 // Возвращает ShURL'ы, у которых deleted = false
 func (r *JSONFileShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL, error) {
@@ -120,10 +173,74 @@ func (r *JSONFileShURLRepository) Get(ctx context.Context, id string) (*entities
 	return nil, errNotFound
 }
 
+// GetWithDeleted - получить ShURL по ID, в том числе удалённую
+func (r *JSONFileShURLRepository) GetWithDeleted(ctx context.Context, id string) (*entities.ShURL, bool, error) {
+	entries, err := r.GetAllEntries(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, entry := range entries {
+		if entry.ShURL.Token == id {
+			return &entry.ShURL, entry.Deleted, nil
+		}
+	}
+
+	return nil, false, errNotFound
+}
+
+// GetByLongURL - получить неудалённые ShURL, указывающие на длинный URL
+// Файл в любом случае считывается целиком, поэтому фильтрация выполняется при проходе по записям
+func (r *JSONFileShURLRepository) GetByLongURL(ctx context.Context, longURL string) ([]entities.ShURL, error) {
+	return r.filter(ctx, func(shurl entities.ShURL) bool {
+		return shurl.LongURL == longURL
+	})
+}
+
+// GetByUserID - получить страницу неудалённых ShURL, созданных пользователем и удовлетворяющих фильтру
+func (r *JSONFileShURLRepository) GetByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]entities.ShURL, *dtos.ShURLCursor, error) {
+	shurls, err := r.filter(ctx, func(shurl entities.ShURL) bool {
+		return shurl.CreatedBy == userID && repository.MatchesFilter(&shurl, filter)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var clicks map[string]int64
+	if filter.Sort == dtos.SortClicks {
+		if clicks, err = r.clickCounts(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	page, next := repository.PageShURLs(shurls, filter, clicks)
+	return page, next, nil
+}
+
+// filter - получить неудалённые ShURL, удовлетворяющие условию
+func (r *JSONFileShURLRepository) filter(ctx context.Context, match func(entities.ShURL) bool) ([]entities.ShURL, error) {
+	entries, err := r.GetAllEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var shurls []entities.ShURL
+	for _, entry := range entries {
+		if !entry.Deleted && match(entry.ShURL) {
+			shurls = append(shurls, entry.ShURL)
+		}
+	}
+
+	return shurls, nil
+}
+
 // Create - создать ShURL
 func (r *JSONFileShURLRepository) Create(ctx context.Context, shurl *entities.ShURL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	//При работе с json-файлом перезаписывается всё содержимое, поэтому работаем с ShURLEntry чтобы не потерять удалённые записи
-	entries, err := r.GetAllEntries(ctx)
+	entries, err := r.readEntries(ctx)
 	if err != nil {
 		return err
 	}
@@ -134,25 +251,53 @@ func (r *JSONFileShURLRepository) Create(ctx context.Context, shurl *entities.Sh
 			return err
 		}
 
-		if entry.ShURL.Token == shurl.Token && !entry.Deleted {
-			return errAlreadyExists
+		// Токены удалённых ShURL повторно не выдаются
+		if entry.ShURL.Token == shurl.Token {
+			return repository.NewAlreadyExistsError(shurl.Token)
 		}
 	}
 
 	entries = append(entries, ShURLEntry{*shurl, false})
 
-	jsonShurls, err := json.MarshalIndent(entries, "", "   ")
+	return r.writeEntries(entries)
+}
+
+// CreateMany - создать ShURL одной перезаписью файла (при коллизии любого токена не создаётся ни один)
+func (r *JSONFileShURLRepository) CreateMany(ctx context.Context, shurls []entities.ShURL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	//При работе с json-файлом перезаписывается всё содержимое, поэтому работаем с ShURLEntry чтобы не потерять удалённые записи
+	entries, err := r.readEntries(ctx)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(r.filePath, jsonShurls, 0644)
+	// Токены удалённых ShURL повторно не выдаются
+	tokens := make(map[string]struct{}, len(entries)+len(shurls))
+	for _, entry := range entries {
+		tokens[entry.ShURL.Token] = struct{}{}
+	}
+
+	for _, shurl := range shurls {
+		if _, exists := tokens[shurl.Token]; exists {
+			return repository.NewAlreadyExistsError(shurl.Token)
+		}
+		tokens[shurl.Token] = struct{}{}
+
+		entries = append(entries, ShURLEntry{shurl, false})
+	}
+
+	return r.writeEntries(entries)
 }
 
 // Update - обновить ShURL
 func (r *JSONFileShURLRepository) Update(ctx context.Context, shurl *entities.ShURL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	//При работе с json-файлом перезаписывается всё содержимое, поэтому работаем с ShURLEntry чтобы не потерять удалённые записи
-	entries, err := r.GetAllEntries(ctx)
+	entries, err := r.readEntries(ctx)
 	if err != nil {
 		return err
 	}
@@ -164,24 +309,60 @@ func (r *JSONFileShURLRepository) Update(ctx context.Context, shurl *entities.Sh
 		}
 
 		if entry.ShURL.Token == shurl.Token && !entry.Deleted {
+			clicksLeft := entry.ShURL.ClicksLeft
 			entries[i].ShURL = *shurl
+			entries[i].ShURL.ClicksLeft = clicksLeft
 
-			jsonShurls, err := json.MarshalIndent(entries, "", "   ")
-			if err != nil {
-				return err
-			}
-
-			return os.WriteFile(r.filePath, jsonShurls, 0644)
+			return r.writeEntries(entries)
 		}
 	}
 
 	return errNotFound
 }
 
+// ConsumeClick - списать переход (чтение и перезапись файла выполняются под одной блокировкой)
+func (r *JSONFileShURLRepository) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := r.readEntries(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i, entry := range entries {
+		if entry.ShURL.Token != id {
+			continue
+		}
+
+		if entry.Deleted {
+			return false, errGone
+		}
+
+		if entry.ShURL.ClicksLeft == nil {
+			return true, nil
+		}
+
+		if *entry.ShURL.ClicksLeft <= 0 {
+			return false, nil
+		}
+
+		left := *entry.ShURL.ClicksLeft - 1
+		entries[i].ShURL.ClicksLeft = &left
+
+		return true, r.writeEntries(entries)
+	}
+
+	return false, errNotFound
+}
+
 // Delete - удалить ShURL
 func (r *JSONFileShURLRepository) Delete(ctx context.Context, ids []string, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	//При работе с json-файлом перезаписывается всё содержимое, поэтому работаем с ShURLEntry чтобы не потерять удалённые записи
-	entries, err := r.GetAllEntries(ctx)
+	entries, err := r.readEntries(ctx)
 	if err != nil {
 		return err
 	}
@@ -200,12 +381,38 @@ func (r *JSONFileShURLRepository) Delete(ctx context.Context, ids []string, user
 		}
 	}
 
-	jsonShurls, err := json.MarshalIndent(entries, "", "   ")
+	return r.writeEntries(entries)
+}
+
+// DeleteExpired - пометить удалёнными ShURL, срок жизни которых истёк к моменту now
+func (r *JSONFileShURLRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	//При работе с json-файлом перезаписывается всё содержимое, поэтому работаем с ShURLEntry чтобы не потерять удалённые записи
+	entries, err := r.readEntries(ctx)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(r.filePath, jsonShurls, 0644)
+	changed := false
+	for i, entry := range entries {
+		if entry.Deleted || entry.ShURL.ExpiresAt == nil {
+			continue
+		}
+
+		if !now.Before(*entry.ShURL.ExpiresAt) {
+			entries[i].Deleted = true
+			changed = true
+		}
+	}
+
+	// Без изменений файл не перезаписываем
+	if !changed {
+		return nil
+	}
+
+	return r.writeEntries(entries)
 }
 
 // CloseConnection - закрыть соединение с базой данных
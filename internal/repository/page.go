@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// PageShURLs - упорядочить отобранные ShURL и вырезать из них страницу по фильтру (для хранилищ без сортировки на стороне БД).
+// clicks - количество переходов по токенам (нужно только для сортировки по переходам).
+// Возвращает курсор следующей страницы (nil - страница последняя)
+func PageShURLs(shurls []entities.ShURL, filter dtos.ShURLFilter, clicks map[string]int64) ([]entities.ShURL, *dtos.ShURLCursor) {
+	cursorOf := func(shurl *entities.ShURL) dtos.ShURLCursor {
+		return NewShURLCursor(shurl, filter, clicks[shurl.Token])
+	}
+
+	slices.SortFunc(shurls, func(a, b entities.ShURL) int {
+		return compareCursors(cursorOf(&a), cursorOf(&b))
+	})
+
+	if filter.After != nil {
+		start, _ := slices.BinarySearchFunc(shurls, *filter.After, func(shurl entities.ShURL, after dtos.ShURLCursor) int {
+			if compareCursors(cursorOf(&shurl), after) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		shurls = shurls[start:]
+	}
+
+	if filter.Limit <= 0 || len(shurls) <= filter.Limit {
+		return shurls, nil
+	}
+
+	shurls = shurls[:filter.Limit]
+	next := cursorOf(&shurls[len(shurls)-1])
+	return shurls, &next
+}
+
+// NewShURLCursor - курсор, указывающий на ShURL в списке, упорядоченном по фильтру (clicks - количество переходов по ссылке)
+func NewShURLCursor(shurl *entities.ShURL, filter dtos.ShURLFilter, clicks int64) dtos.ShURLCursor {
+	cursor := dtos.ShURLCursor{Sort: filter.Sort, Desc: filter.Desc, Token: shurl.Token}
+	switch filter.Sort {
+	case dtos.SortClicks:
+		cursor.Clicks = clicks
+	case dtos.SortAlias:
+	default:
+		cursor.CreatedAt = shurl.CreatedAt
+	}
+
+	return cursor
+}
+
+// compareCursors - сравнить позиции в списке с учётом поля и направления сортировки
+func compareCursors(a, b dtos.ShURLCursor) int {
+	var result int
+	switch a.Sort {
+	case dtos.SortClicks:
+		result = cmp.Compare(a.Clicks, b.Clicks)
+	case dtos.SortAlias:
+	default:
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if result == 0 {
+		result = strings.Compare(a.Token, b.Token)
+	}
+
+	if a.Desc {
+		return -result
+	}
+	return result
+}
+
+// LikePattern - шаблон LIKE для поиска подстроки (символы шаблона экранируются обратной косой чертой)
+func LikePattern(search string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(search) + "%"
+}
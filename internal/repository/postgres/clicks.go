@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// AddClicks - сохранить пачку событий переходов (одним COPY)
+func (r *PostgresShURLRepository) AddClicks(ctx context.Context, clicks []entities.Click) error {
+	_, err := r.db.CopyFrom(
+		ctx,
+		pgx.Identifier{"clicks"},
+		[]string{"token", "clickedat", "referrer", "useragent", "ip", "variant"},
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+			click := clicks[i]
+			return []any{click.Token, click.Timestamp, click.Referrer, click.UserAgent, click.IP, click.Variant}, nil
+		}),
+	)
+	return err
+}
+
+// GetClickStats - получить статистику переходов по токену
+func (r *PostgresShURLRepository) GetClickStats(ctx context.Context, token string) (*dtos.ClickStats, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT (clickedat AT TIME ZONE 'UTC')::date AS day, COUNT(*)
+		FROM clicks
+		WHERE token = $1
+		GROUP BY day
+		ORDER BY day
+	`, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats dtos.ClickStats
+	for rows.Next() {
+		var daily dtos.DailyClicks
+		if err := rows.Scan(&daily.Day, &daily.Clicks); err != nil {
+			return nil, err
+		}
+
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.Variants, err = r.getVariantClicks(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// getVariantClicks - получить количество переходов по вариантам A/B-теста
+func (r *PostgresShURLRepository) getVariantClicks(ctx context.Context, token string) ([]dtos.VariantClicks, error) {
+	rows, err := r.db.Query(ctx, "SELECT variant, COUNT(*) FROM clicks WHERE token = $1 AND variant <> '' GROUP BY variant ORDER BY variant", token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []dtos.VariantClicks
+	for rows.Next() {
+		var variant dtos.VariantClicks
+		if err := rows.Scan(&variant.Variant, &variant.Clicks); err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
@@ -6,10 +6,15 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 //КАК ЗАКОММЕНТИРОВАТЬ КОММЕНТАРИЙ go:embed config.json
@@ -25,13 +30,68 @@ import (
 // }
 
 // PostgresShURLRepository - репозиторий
+// Используется пул соединений: pgx.Conn не допускает конкурентного использования, а события переходов пишутся из фоновой горутины
 type PostgresShURLRepository struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
+// shurlColumns - колонки таблицы shurls, соответствующие полям entities.ShURL (порядок важен для scanShURL)
+const shurlColumns = "token, longurl, createdby, expiresat, passwordhash, clicksleft, notbefore, notafter, createdat, title, redirectstatus, passquery, utmsource, utmmedium, utmcampaign, queryconflict, targeting, variants, stickyvariants, folder"
+
+// shurlSelectColumns - колонки запроса ShURL: shurlColumns и метки ссылки из таблицы shurl_tags (по возрастанию)
+const shurlSelectColumns = shurlColumns + ", ARRAY(SELECT tag FROM shurl_tags WHERE shurl_tags.token = shurls.token ORDER BY tag)"
+
+// migrations - изменения схемы таблицы shurls, применяемые в том числе к ранее созданным таблицам
+var migrations = []string{
+	// Расширение колонки token для пользовательских алиасов
+	"ALTER TABLE shurls ALTER COLUMN token TYPE VARCHAR(64)",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS expiresat TIMESTAMPTZ",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS passwordhash TEXT NOT NULL DEFAULT ''",
+	// Оставшееся количество переходов (NULL - без ограничения)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS clicksleft INTEGER",
+	// Окно активности ссылки (NULL - без ограничения с соответствующей стороны)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS notbefore TIMESTAMPTZ",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS notafter TIMESTAMPTZ",
+	// Момент создания (NULL - ссылка создана до появления колонки) и заголовок для страницы предпросмотра
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS createdat TIMESTAMPTZ",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT ''",
+	// Код ответа при переходе (0 - код по умолчанию сервера)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS redirectstatus INTEGER NOT NULL DEFAULT 0",
+	// Правила формирования query-строки адреса назначения (UTM-метки и передача параметров запроса)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS passquery BOOLEAN NOT NULL DEFAULT false",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS utmsource TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS utmmedium TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS utmcampaign TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS queryconflict TEXT NOT NULL DEFAULT ''",
+	// Правила выбора адреса назначения (JSON-массив entities.TargetingRule)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS targeting JSONB NOT NULL DEFAULT '[]'",
+	// Варианты A/B-теста (JSON-массив entities.Variant)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]'",
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS stickyvariants BOOLEAN NOT NULL DEFAULT false",
+	// Папка ссылки в кабинете пользователя ('' - вне папок)
+	"ALTER TABLE shurls ADD COLUMN IF NOT EXISTS folder TEXT NOT NULL DEFAULT ''",
+	// Дубли ищутся в пределах пользователя, поэтому глобальная уникальность longurl снята.
+	// Индекс не уникальный (даже частичный - по ссылкам, которые могут быть дублями): ссылка с алиасом может повторять
+	// уже укороченный пользователем URL, а метки, исключающие ссылку из дублей, хранятся в shurl_tags и в условие
+	// индекса не попадают. Отсутствие дублей обеспечивает сервис: создания одного пользователя (при глобальной дедупликации -
+	// все создания) выполняются по очереди
+	"ALTER TABLE shurls DROP CONSTRAINT IF EXISTS shurls_longurl_key",
+	"CREATE INDEX IF NOT EXISTS shurls_createdby_longurl_idx ON shurls (createdby, longurl)",
+	// Индекс для поиска дублей без учёта пользователя (createdby - ведущая колонка предыдущего индекса)
+	"CREATE INDEX IF NOT EXISTS shurls_longurl_idx ON shurls (longurl)",
+}
+
+// insertShURLQuery - вставка ShURL. ON CONFLICT вместо разбора кода ошибки:
+// занятый токен (в т.ч. удалённой ссылкой) не прерывает транзакцию, а определяется по количеству вставленных строк
+const insertShURLQuery = "INSERT INTO shurls (" + shurlColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20) ON CONFLICT (token) DO NOTHING"
+
+// insertTagsQuery - вставка меток ShURL одним запросом (метки передаются массивом)
+const insertTagsQuery = "INSERT INTO shurl_tags (token, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING"
+
 // Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
 var (
-	errGone = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errGone     = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errNotFound = customerrors.NewNotFoundError(errors.New("not found"))
 )
 
 // NewJSONFileShURLRepository - инициализация репозитория
@@ -69,7 +129,7 @@ func NewPostgresShURLRepository(connStr string) (*PostgresShURLRepository, error
 	// }
 
 	// Подключение к базе данных
-	db, err := pgx.Connect(context.Background(), connStr)
+	db, err := pgxpool.New(context.Background(), connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -82,8 +142,8 @@ func NewPostgresShURLRepository(connStr string) (*PostgresShURLRepository, error
 	// Создание таблицы shurls, если её нет
 	_, err = db.Exec(context.Background(), `
 		CREATE TABLE IF NOT EXISTS shurls (
-			token VARCHAR(8) PRIMARY KEY,
-			longurl TEXT NOT NULL UNIQUE,
+			token VARCHAR(64) PRIMARY KEY,
+			longurl TEXT NOT NULL,
 			createdby TEXT NOT NULL,
 			deleted BOOLEAN DEFAULT false
 		)
@@ -92,30 +152,180 @@ func NewPostgresShURLRepository(connStr string) (*PostgresShURLRepository, error
 		return nil, fmt.Errorf("failed to create table shurls: %w", err)
 	}
 
+	// Приведение схемы таблицы shurls к актуальной
+	for _, migration := range migrations {
+		if _, err = db.Exec(context.Background(), migration); err != nil {
+			return nil, fmt.Errorf("failed to migrate table shurls: %w", err)
+		}
+	}
+
+	// Создание таблицы меток shurl_tags, если её нет (индекс по метке - для отбора ссылок пользователя по метке)
+	_, err = db.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS shurl_tags (
+			token VARCHAR(64) NOT NULL REFERENCES shurls (token),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (token, tag)
+		);
+		CREATE INDEX IF NOT EXISTS shurl_tags_tag_idx ON shurl_tags (tag);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table shurl_tags: %w", err)
+	}
+
+	// Создание таблицы событий переходов clicks, если её нет
+	_, err = db.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS clicks (
+			id BIGSERIAL PRIMARY KEY,
+			token VARCHAR(64) NOT NULL,
+			clickedat TIMESTAMPTZ NOT NULL,
+			referrer TEXT NOT NULL DEFAULT '',
+			useragent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS clicks_token_idx ON clicks (token, clickedat);
+		ALTER TABLE clicks ADD COLUMN IF NOT EXISTS variant TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table clicks: %w", err)
+	}
+
 	return &PostgresShURLRepository{db: db}, nil
 }
 
 // GetAll - получить все ShURL
 func (r *PostgresShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL, error) {
-	rows, err := r.db.Query(ctx, "SELECT token, longurl, createdby FROM shurls WHERE deleted = false")
+	return r.query(ctx, "SELECT "+shurlSelectColumns+" FROM shurls WHERE deleted = false")
+}
+
+// GetByLongURL - получить неудалённые ShURL, указывающие на длинный URL
+func (r *PostgresShURLRepository) GetByLongURL(ctx context.Context, longURL string) ([]entities.ShURL, error) {
+	return r.query(ctx, "SELECT "+shurlSelectColumns+" FROM shurls WHERE longurl = $1 AND deleted = false", longURL)
+}
+
+// sortKeys - выражения ключей сортировки списка ShURL пользователя.
+// Ссылки, созданные до появления колонки createdat, получают нулевой момент создания (как и в entities.ShURL)
+var sortKeys = map[dtos.ShURLSort]string{
+	dtos.SortCreated: "COALESCE(createdat, '0001-01-01 00:00:00+00')",
+	dtos.SortClicks:  "(SELECT COUNT(*) FROM clicks WHERE clicks.token = shurls.token)",
+	dtos.SortAlias:   "token",
+}
+
+// GetByUserID - получить страницу неудалённых ShURL, созданных пользователем и удовлетворяющих фильтру.
+// Страница выбирается по ключу сортировки (keyset): следующая начинается строго после последней ссылки предыдущей
+func (r *PostgresShURLRepository) GetByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]entities.ShURL, *dtos.ShURLCursor, error) {
+	key, ok := sortKeys[filter.Sort]
+	if !ok {
+		key = sortKeys[dtos.SortCreated]
+	}
+
+	columns := shurlSelectColumns
+	if filter.Sort == dtos.SortClicks {
+		columns += ", " + key
+	}
+
+	query := "SELECT " + columns + " FROM shurls WHERE createdby = $1 AND deleted = false"
+	args := []any{userID}
+
+	if filter.Folder != "" {
+		args = append(args, filter.Folder)
+		query += fmt.Sprintf(" AND folder = $%d", len(args))
+	}
+
+	// Каждая метка фильтра - отдельное условие EXISTS по первичному ключу shurl_tags
+	for _, tag := range filter.Tags {
+		args = append(args, tag)
+		query += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM shurl_tags WHERE shurl_tags.token = shurls.token AND tag = $%d)", len(args))
+	}
+
+	if filter.Search != "" {
+		args = append(args, repository.LikePattern(filter.Search))
+		query += fmt.Sprintf(" AND (longurl ILIKE $%[1]d OR title ILIKE $%[1]d)", len(args))
+	}
+
+	op, direction := ">", "ASC"
+	if filter.Desc {
+		op, direction = "<", "DESC"
+	}
+
+	if after := filter.After; after != nil {
+		switch filter.Sort {
+		case dtos.SortAlias:
+			args = append(args, after.Token)
+			query += fmt.Sprintf(" AND token %s $%d", op, len(args))
+		case dtos.SortClicks:
+			args = append(args, after.Clicks, after.Token)
+			query += fmt.Sprintf(" AND (%s, token) %s ($%d, $%d)", key, op, len(args)-1, len(args))
+		default:
+			args = append(args, after.CreatedAt, after.Token)
+			query += fmt.Sprintf(" AND (%s, token) %s ($%d, $%d)", key, op, len(args)-1, len(args))
+		}
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, token %s", key, direction, direction)
+
+	// Лишняя строка сверх лимита показывает, что за страницей есть следующая
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit+1)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer rows.Close()
 
+	var shurls []entities.ShURL
+	var lastClicks int64
+	for rows.Next() {
+		var clicks int64
+		var extra []any
+		if filter.Sort == dtos.SortClicks {
+			extra = append(extra, &clicks)
+		}
+
+		shurl, err := scanShURL(rows, extra...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if filter.Limit > 0 && len(shurls) == filter.Limit {
+			next := repository.NewShURLCursor(&shurls[len(shurls)-1], filter, lastClicks)
+			return shurls, &next, nil
+		}
+
+		shurls = append(shurls, *shurl)
+		lastClicks = clicks
+	}
+
 	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return shurls, nil, nil
+}
+
+// query - выполнить запрос, возвращающий колонки shurlSelectColumns, и считать результат
+func (r *PostgresShURLRepository) query(ctx context.Context, sql string, args ...any) ([]entities.ShURL, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	var shurls []entities.ShURL
 	for rows.Next() {
-		var shurl entities.ShURL
-		err := rows.Scan(&shurl.Token, &shurl.LongURL, &shurl.CreatedBy)
+		shurl, err := scanShURL(rows)
 		if err != nil {
 			return nil, err
 		}
-		shurls = append(shurls, shurl)
+		shurls = append(shurls, *shurl)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return shurls, nil
@@ -123,33 +333,168 @@ func (r *PostgresShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL,
 
 // Get - получить ShURL по ID (токену)
 func (r *PostgresShURLRepository) Get(ctx context.Context, id string) (*entities.ShURL, error) {
-	var shurl entities.ShURL
-	var deleted bool
-	err := r.db.QueryRow(ctx, "SELECT token, longurl, createdby, deleted FROM shurls WHERE token = $1", id).Scan(&shurl.Token, &shurl.LongURL, &shurl.CreatedBy, &deleted)
+	shurl, deleted, err := r.GetWithDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
 	if deleted {
 		return nil, errGone
 	}
 
+	return shurl, nil
+}
+
+// GetWithDeleted - получить ShURL по ID, в том числе удалённую
+func (r *PostgresShURLRepository) GetWithDeleted(ctx context.Context, id string) (*entities.ShURL, bool, error) {
+	var deleted bool
+	shurl, err := scanShURL(r.db.QueryRow(ctx, "SELECT "+shurlSelectColumns+", deleted FROM shurls WHERE token = $1", id), &deleted)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, errNotFound
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return &shurl, nil
+
+	return shurl, deleted, nil
 }
 
 // Create - создать ShURL
 func (r *PostgresShURLRepository) Create(ctx context.Context, shurl *entities.ShURL) error {
-	_, err := r.db.Exec(ctx, "INSERT INTO shurls (token, longurl, createdBy) VALUES ($1, $2, $3)", shurl.Token, shurl.LongURL, shurl.CreatedBy)
+	args, err := insertShURLArgs(shurl)
 	if err != nil {
 		return err
 	}
+
+	// Ссылка без меток вставляется одним запросом, с метками - в транзакции вместе с ними
+	if len(shurl.Tags) == 0 {
+		return insertShURL(ctx, r.db, shurl.Token, args)
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := insertShURL(ctx, tx, shurl.Token, args); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, insertTagsQuery, shurl.Token, shurl.Tags)
+		return err
+	})
+}
+
+// execer - *pgxpool.Pool или pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// insertShURL - выполнить insertShURLQuery (занятый токен - AlreadyExistsError)
+func insertShURL(ctx context.Context, db execer, token string, args []any) error {
+	tag, err := db.Exec(ctx, insertShURLQuery, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.NewAlreadyExistsError(token)
+	}
+
 	return nil
 }
 
-// Update - обновить ShURL
+// CreateMany - создать ShURL в одной транзакции. Вставки отправляются одним пакетом (один round trip),
+// при коллизии любого токена транзакция откатывается целиком
+func (r *PostgresShURLRepository) CreateMany(ctx context.Context, shurls []entities.ShURL) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for _, shurl := range shurls {
+			args, err := insertShURLArgs(&shurl)
+			if err != nil {
+				return err
+			}
+			batch.Queue(insertShURLQuery, args...)
+			if len(shurl.Tags) > 0 {
+				batch.Queue(insertTagsQuery, shurl.Token, shurl.Tags)
+			}
+		}
+
+		results := tx.SendBatch(ctx, batch)
+		defer results.Close()
+
+		for _, shurl := range shurls {
+			tag, err := results.Exec()
+			if err != nil {
+				return err
+			}
+
+			if tag.RowsAffected() == 0 {
+				return repository.NewAlreadyExistsError(shurl.Token)
+			}
+
+			if len(shurl.Tags) > 0 {
+				if _, err := results.Exec(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return results.Close()
+	})
+}
+
+// Update - обновить ShURL (метки заменяются целиком в той же транзакции)
 func (r *PostgresShURLRepository) Update(ctx context.Context, shurl *entities.ShURL) error {
-	_, err := r.db.Exec(ctx, "UPDATE shurls SET longurl = $2, createdby = $3 WHERE token = $1", shurl.Token, shurl.LongURL, shurl.CreatedBy)
-	return err
+	targeting, err := repository.MarshalJSONColumn(shurl.Targeting)
+	if err != nil {
+		return err
+	}
+
+	variants, err := repository.MarshalJSONColumn(shurl.Variants)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE shurls SET longurl = $2, createdby = $3, expiresat = $4, passwordhash = $5, notbefore = $6, notafter = $7, title = $8, redirectstatus = $9, passquery = $10, utmsource = $11, utmmedium = $12, utmcampaign = $13, queryconflict = $14, targeting = $15, variants = $16, stickyvariants = $17, folder = $18 WHERE token = $1 AND deleted = false", shurl.Token, shurl.LongURL, shurl.CreatedBy, shurl.ExpiresAt, shurl.PasswordHash, shurl.NotBefore, shurl.NotAfter, shurl.Title, shurl.RedirectStatus, shurl.Query.Passthrough, shurl.Query.UTMSource, shurl.Query.UTMMedium, shurl.Query.UTMCampaign, shurl.Query.Conflict, targeting, variants, shurl.StickyVariants, shurl.Folder)
+		if err != nil {
+			return err
+		}
+
+		// Отсутствующая или удалённая ссылка не обновляется - как и в репозитории в памяти, это errNotFound
+		if tag.RowsAffected() == 0 {
+			return errNotFound
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM shurl_tags WHERE token = $1", shurl.Token); err != nil {
+			return err
+		}
+
+		if len(shurl.Tags) == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, insertTagsQuery, shurl.Token, shurl.Tags)
+		return err
+	})
+}
+
+// ConsumeClick - списать переход условным обновлением: из двух параллельных переходов последний доступный получит только один
+func (r *PostgresShURLRepository) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Exec(ctx, "UPDATE shurls SET clicksleft = clicksleft - 1 WHERE token = $1 AND deleted = false AND (clicksleft IS NULL OR clicksleft > 0)", id)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	// Строка не обновлена: переходы исчерпаны, либо ссылки нет или она удалена
+	if _, err := r.Get(ctx, id); err != nil {
+		return false, err
+	}
+
+	return false, nil
 }
 
 // Delete - удалить ShURL
@@ -158,9 +503,60 @@ func (r *PostgresShURLRepository) Delete(ctx context.Context, ids []string, user
 	return err
 }
 
+// DeleteExpired - пометить удалёнными ShURL, срок жизни которых истёк к моменту now
+func (r *PostgresShURLRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE shurls SET deleted = true WHERE deleted = false AND expiresat <= $1", now)
+	return err
+}
+
+// insertShURLArgs - аргументы insertShURLQuery для ShURL
+func insertShURLArgs(shurl *entities.ShURL) ([]any, error) {
+	targeting, err := repository.MarshalJSONColumn(shurl.Targeting)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := repository.MarshalJSONColumn(shurl.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{shurl.Token, shurl.LongURL, shurl.CreatedBy, shurl.ExpiresAt, shurl.PasswordHash, shurl.ClicksLeft, shurl.NotBefore, shurl.NotAfter, shurl.CreatedAt, shurl.Title, shurl.RedirectStatus, shurl.Query.Passthrough, shurl.Query.UTMSource, shurl.Query.UTMMedium, shurl.Query.UTMCampaign, shurl.Query.Conflict, targeting, variants, shurl.StickyVariants, shurl.Folder}, nil
+}
+
+// scanShURL - считать ShURL из строки результата запроса (extra - колонки, следующие в запросе за shurlSelectColumns)
+func scanShURL(row pgx.Row, extra ...any) (*entities.ShURL, error) {
+	var shurl entities.ShURL
+	var createdAt *time.Time
+	var targeting, variants string
+	dest := append([]any{&shurl.Token, &shurl.LongURL, &shurl.CreatedBy, &shurl.ExpiresAt, &shurl.PasswordHash, &shurl.ClicksLeft, &shurl.NotBefore, &shurl.NotAfter, &createdAt, &shurl.Title, &shurl.RedirectStatus, &shurl.Query.Passthrough, &shurl.Query.UTMSource, &shurl.Query.UTMMedium, &shurl.Query.UTMCampaign, &shurl.Query.Conflict, &targeting, &variants, &shurl.StickyVariants, &shurl.Folder, &shurl.Tags}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if shurl.Targeting, err = repository.UnmarshalJSONColumn[entities.TargetingRule](targeting); err != nil {
+		return nil, err
+	}
+	if shurl.Variants, err = repository.UnmarshalJSONColumn[entities.Variant](variants); err != nil {
+		return nil, err
+	}
+
+	if createdAt != nil {
+		shurl.CreatedAt = *createdAt
+	}
+
+	// Ссылка без меток - nil, как и в остальных хранилищах
+	if len(shurl.Tags) == 0 {
+		shurl.Tags = nil
+	}
+
+	return &shurl, nil
+}
+
 // CloseConnection - закрыть соединение с базой данных
 func (r *PostgresShURLRepository) CloseConnection() {
-	r.db.Close(context.Background())
+	r.db.Close()
 }
 
 // PingDB - проверить подключение к базе данных
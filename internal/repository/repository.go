@@ -3,7 +3,13 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
 )
 
@@ -13,15 +19,124 @@ type IRepository[T entities.IEntity] interface {
 	GetAll(ctx context.Context) ([]T, error)
 	// Get - получить сущность по ID
 	Get(ctx context.Context, id string) (*T, error)
+	// GetWithDeleted - получить сущность по ID, в том числе помеченную удалённой (deleted - сущность удалена)
+	GetWithDeleted(ctx context.Context, id string) (entity *T, deleted bool, err error)
+	// GetByLongURL - получить неудалённые сущности, указывающие на длинный URL
+	GetByLongURL(ctx context.Context, longURL string) ([]T, error)
+	// GetByUserID - получить страницу неудалённых сущностей, созданных пользователем и удовлетворяющих фильтру,
+	// в порядке сортировки фильтра. Возвращает курсор следующей страницы (nil - страница последняя)
+	GetByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]T, *dtos.ShURLCursor, error)
 	// Create - создать сущность
 	Create(ctx context.Context, IEntity *T) error
-	// Update - обновить сущность
+	// CreateMany - создать сущности атомарно: либо все, либо (при любой ошибке) ни одной
+	CreateMany(ctx context.Context, IEntities []T) error
+	// Update - обновить сущность (счётчик оставшихся переходов не изменяется - только через ConsumeClick)
 	Update(ctx context.Context, IEntity *T) error
+	// ConsumeClick - атомарно списать один переход с сущности, количество переходов по которой ограничено.
+	// Возвращает false, если переходы исчерпаны (для сущностей без ограничения - всегда true)
+	ConsumeClick(ctx context.Context, id string) (bool, error)
 	// Delete - удалить сущность
 	Delete(ctx context.Context, id []string, userID string) error
+	// DeleteExpired - пометить удалёнными сущности, срок жизни которых истёк к моменту now
+	DeleteExpired(ctx context.Context, now time.Time) error
 
 	// CloseConnection - закрыть соединение с базой данных
 	CloseConnection()
 	// PingDB - проверить подключение к базе данных
 	PingDB() bool
 }
+
+// IClickRepository - хранилище событий переходов по укороченным ссылкам
+type IClickRepository interface {
+	// AddClicks - сохранить пачку событий переходов
+	AddClicks(ctx context.Context, clicks []entities.Click) error
+	// GetClickStats - получить статистику переходов по токену
+	GetClickStats(ctx context.Context, token string) (*dtos.ClickStats, error)
+}
+
+// AggregateClicks - посчитать статистику по событиям переходов (для хранилищ без агрегации на стороне БД)
+func AggregateClicks(clicks []entities.Click) *dtos.ClickStats {
+	stats := &dtos.ClickStats{Total: int64(len(clicks))}
+
+	perDay := make(map[time.Time]int64)
+	perVariant := make(map[string]int64)
+	for _, click := range clicks {
+		ts := click.Timestamp.UTC()
+		day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+		perDay[day]++
+
+		if click.Variant != "" {
+			perVariant[click.Variant]++
+		}
+	}
+
+	for day, count := range perDay {
+		stats.Daily = append(stats.Daily, dtos.DailyClicks{Day: day, Clicks: count})
+	}
+
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Day.Before(stats.Daily[j].Day)
+	})
+
+	for variant, count := range perVariant {
+		stats.Variants = append(stats.Variants, dtos.VariantClicks{Variant: variant, Clicks: count})
+	}
+
+	sort.Slice(stats.Variants, func(i, j int) bool {
+		return stats.Variants[i].Variant < stats.Variants[j].Variant
+	})
+
+	return stats
+}
+
+// MatchesFilter - проверить, удовлетворяет ли ShURL фильтру (для хранилищ без фильтрации на стороне БД)
+func MatchesFilter(shurl *entities.ShURL, filter dtos.ShURLFilter) bool {
+	if filter.Folder != "" && shurl.Folder != filter.Folder {
+		return false
+	}
+
+	for _, tag := range filter.Tags {
+		if !slices.Contains(shurl.Tags, tag) {
+			return false
+		}
+	}
+
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		return strings.Contains(strings.ToLower(shurl.LongURL), search) || strings.Contains(strings.ToLower(shurl.Title), search)
+	}
+
+	return true
+}
+
+// MarshalJSONColumn - сериализовать список значений (правила выбора адреса, варианты A/B-теста) для хранения в колонке БД
+func MarshalJSONColumn[T any](values []T) (string, error) {
+	if len(values) == 0 {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// UnmarshalJSONColumn - восстановить список значений из колонки БД (пустое значение или пустой массив - nil)
+func UnmarshalJSONColumn[T any](data string) ([]T, error) {
+	var values []T
+	if data == "" {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return values, nil
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// AddClicks - сохранить пачку событий переходов (в одной транзакции)
+func (r *SQLiteShURLRepository) AddClicks(ctx context.Context, clicks []entities.Click) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO clicks (token, clickedat, referrer, useragent, ip, variant) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		if _, err := stmt.ExecContext(ctx, click.Token, click.Timestamp.UnixMilli(), click.Referrer, click.UserAgent, click.IP, click.Variant); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetClickStats - получить статистику переходов по токену
+func (r *SQLiteShURLRepository) GetClickStats(ctx context.Context, token string) (*dtos.ClickStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT date(clickedat / 1000, 'unixepoch') AS day, COUNT(*)
+		FROM clicks
+		WHERE token = ?
+		GROUP BY day
+		ORDER BY day
+	`, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats dtos.ClickStats
+	for rows.Next() {
+		var day string
+		var daily dtos.DailyClicks
+		if err := rows.Scan(&day, &daily.Clicks); err != nil {
+			return nil, err
+		}
+
+		daily.Day, err = time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.Variants, err = r.getVariantClicks(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// getVariantClicks - получить количество переходов по вариантам A/B-теста
+func (r *SQLiteShURLRepository) getVariantClicks(ctx context.Context, token string) ([]dtos.VariantClicks, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT variant, COUNT(*) FROM clicks WHERE token = ? AND variant <> '' GROUP BY variant ORDER BY variant", token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []dtos.VariantClicks
+	for rows.Next() {
+		var variant dtos.VariantClicks
+		if err := rows.Scan(&variant.Variant, &variant.Clicks); err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
@@ -4,16 +4,21 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
-	_ "modernc.org/sqlite"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+	"modernc.org/sqlite"
 )
 
 // configContent - содержимое конфигурационного файла подключения к базе данных
@@ -31,11 +36,60 @@ type SQLiteShURLRepository struct {
 	db *sql.DB
 }
 
+// shurlColumns - колонки таблицы shurls, соответствующие полям entities.ShURL (порядок важен для scanShURL)
+const shurlColumns = "token, longurl, createdby, expiresat, passwordhash, clicksleft, notbefore, notafter, createdat, title, redirectstatus, passquery, utmsource, utmmedium, utmcampaign, queryconflict, targeting, variants, stickyvariants, folder"
+
+// shurlSelectColumns - колонки запроса ShURL: shurlColumns и метки ссылки из таблицы shurl_tags (JSON-массив по возрастанию)
+const shurlSelectColumns = shurlColumns + ", (SELECT json_group_array(tag) FROM (SELECT tag FROM shurl_tags WHERE shurl_tags.token = shurls.token ORDER BY tag))"
+
+// columnMigrations - колонки, добавленные в таблицу shurls после её первоначального создания
+// Время хранится в виде unix-миллисекунд (INTEGER) для сравнения на стороне БД
+var columnMigrations = []struct {
+	name       string
+	definition string
+}{
+	{"expiresat", "INTEGER"},
+	{"passwordhash", "TEXT NOT NULL DEFAULT ''"},
+	{"clicksleft", "INTEGER"}, // NULL - без ограничения
+	{"notbefore", "INTEGER"},
+	{"notafter", "INTEGER"},
+	{"createdat", "INTEGER"}, // NULL - ссылка создана до появления колонки
+	{"title", "TEXT NOT NULL DEFAULT ''"},
+	{"redirectstatus", "INTEGER NOT NULL DEFAULT 0"}, // 0 - код по умолчанию сервера
+	{"passquery", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"utmsource", "TEXT NOT NULL DEFAULT ''"},
+	{"utmmedium", "TEXT NOT NULL DEFAULT ''"},
+	{"utmcampaign", "TEXT NOT NULL DEFAULT ''"},
+	{"queryconflict", "TEXT NOT NULL DEFAULT ''"},
+	{"targeting", "TEXT NOT NULL DEFAULT '[]'"}, // JSON-массив entities.TargetingRule
+	{"variants", "TEXT NOT NULL DEFAULT '[]'"},  // JSON-массив entities.Variant
+	{"stickyvariants", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"folder", "TEXT NOT NULL DEFAULT ''"},
+}
+
 // Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
 var (
-	errGone = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errGone     = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	errNotFound = customerrors.NewNotFoundError(errors.New("not found"))
 )
 
+// unicodeLowerFunc - имя SQL-функции, приводящей строку к нижнему регистру так же, как strings.ToLower.
+// Встроенные lower() и LIKE SQLite не учитывают регистр только для латиницы
+const unicodeLowerFunc = "unicode_lower"
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction(unicodeLowerFunc, 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch value := args[0].(type) {
+		case string:
+			return strings.ToLower(value), nil
+		case []byte:
+			return strings.ToLower(string(value)), nil
+		default:
+			return value, nil
+		}
+	})
+}
+
 // NewSQLiteShURLRepository - инициализация репозитория
 func NewSQLiteShURLRepository() (*SQLiteShURLRepository, error) {
 	//TODO: задействовать context при создании, подключении БД
@@ -51,7 +105,9 @@ func NewSQLiteShURLRepository() (*SQLiteShURLRepository, error) {
 	}
 
 	// Открываем (или создаем) базу данных
-	db, err := sql.Open("sqlite", "file:"+conf.Path)
+	// busy_timeout задаётся в DSN, чтобы применяться к каждому соединению пула: запись из нескольких воркеров сервиса
+	// ожидает освобождения блокировки вместо немедленной ошибки SQLITE_BUSY
+	db, err := sql.Open("sqlite", "file:"+conf.Path+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -79,16 +135,208 @@ func NewSQLiteShURLRepository() (*SQLiteShURLRepository, error) {
 		return nil, fmt.Errorf("failed to create table shurls: %w", err)
 	}
 
+	// Приведение схемы таблицы shurls к актуальной
+	for _, column := range columnMigrations {
+		if err := addColumnIfNotExists(db, "shurls", column.name, column.definition); err != nil {
+			return nil, fmt.Errorf("failed to migrate table shurls: %w", err)
+		}
+	}
+
+	// Индексы для поиска дублей - те же, что и в postgres: среди ссылок пользователя (createdby - ведущая колонка,
+	// поэтому отдельный индекс по создателю не нужен) и без учёта пользователя. Индексы не уникальные - см. postgres.migrations
+	_, err = db.Exec(`
+		DROP INDEX IF EXISTS shurls_createdby_idx;
+		CREATE INDEX IF NOT EXISTS shurls_createdby_longurl_idx ON shurls (createdby, longurl);
+		CREATE INDEX IF NOT EXISTS shurls_longurl_idx ON shurls (longurl);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create indexes on shurls: %w", err)
+	}
+
+	// Создаем таблицу меток ссылок (индекс по метке - для отбора ссылок пользователя по метке)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS shurl_tags (
+			token TEXT NOT NULL REFERENCES shurls (token),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (token, tag)
+		);
+		CREATE INDEX IF NOT EXISTS shurl_tags_tag_idx ON shurl_tags (tag);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table shurl_tags: %w", err)
+	}
+
+	// Создаем таблицу событий переходов
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS clicks (
+			token TEXT NOT NULL,
+			clickedat INTEGER NOT NULL,
+			referrer TEXT NOT NULL DEFAULT '',
+			useragent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS clicks_token_idx ON clicks (token, clickedat);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table clicks: %w", err)
+	}
+
+	// ID показанного варианта A/B-теста ("" - у ссылки нет вариантов)
+	if err := addColumnIfNotExists(db, "clicks", "variant", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("failed to migrate table clicks: %w", err)
+	}
+
 	return &SQLiteShURLRepository{db: db}, nil
 }
 
+// addColumnIfNotExists - добавить колонку в таблицу, если её там ещё нет (SQLite не поддерживает ADD COLUMN IF NOT EXISTS)
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // GetAll - получить все ShURL
 func (r *SQLiteShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT token, longurl, createdby FROM shurls WHERE deleted = FALSE")
+	return r.query(ctx, "SELECT "+shurlSelectColumns+" FROM shurls WHERE deleted = FALSE")
+}
+
+// GetByLongURL - получить неудалённые ShURL, указывающие на длинный URL
+func (r *SQLiteShURLRepository) GetByLongURL(ctx context.Context, longURL string) ([]entities.ShURL, error) {
+	return r.query(ctx, "SELECT "+shurlSelectColumns+" FROM shurls WHERE longurl = ? AND deleted = FALSE", longURL)
+}
+
+// sortKeys - выражения ключей сортировки списка ShURL пользователя.
+// Ссылки, созданные до появления колонки createdat, получают нулевой момент создания (как и в entities.ShURL)
+var sortKeys = map[dtos.ShURLSort]string{
+	dtos.SortCreated: fmt.Sprintf("COALESCE(createdat, %d)", time.Time{}.UnixMilli()),
+	dtos.SortClicks:  "(SELECT COUNT(*) FROM clicks WHERE clicks.token = shurls.token)",
+	dtos.SortAlias:   "token",
+}
+
+// GetByUserID - получить страницу неудалённых ShURL, созданных пользователем и удовлетворяющих фильтру.
+// Страница выбирается по ключу сортировки (keyset): следующая начинается строго после последней ссылки предыдущей
+func (r *SQLiteShURLRepository) GetByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]entities.ShURL, *dtos.ShURLCursor, error) {
+	key, ok := sortKeys[filter.Sort]
+	if !ok {
+		key = sortKeys[dtos.SortCreated]
+	}
+
+	columns := shurlSelectColumns
+	if filter.Sort == dtos.SortClicks {
+		columns += ", " + key
+	}
+
+	query := "SELECT " + columns + " FROM shurls WHERE createdby = ? AND deleted = FALSE"
+	args := []any{userID}
+
+	if filter.Folder != "" {
+		query += " AND folder = ?"
+		args = append(args, filter.Folder)
+	}
+
+	// Каждая метка фильтра - отдельное условие EXISTS по первичному ключу shurl_tags
+	for _, tag := range filter.Tags {
+		query += " AND EXISTS (SELECT 1 FROM shurl_tags WHERE shurl_tags.token = shurls.token AND tag = ?)"
+		args = append(args, tag)
+	}
+
+	// LIKE в SQLite не учитывает регистр только для латиницы, поэтому обе стороны приводятся к нижнему регистру
+	// unicode_lower - так же, как при поиске в памяти
+	if filter.Search != "" {
+		pattern := repository.LikePattern(strings.ToLower(filter.Search))
+		query += ` AND (` + unicodeLowerFunc + `(longurl) LIKE ? ESCAPE '\' OR ` + unicodeLowerFunc + `(title) LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern)
+	}
+
+	op, direction := ">", "ASC"
+	if filter.Desc {
+		op, direction = "<", "DESC"
+	}
+
+	if after := filter.After; after != nil {
+		switch filter.Sort {
+		case dtos.SortAlias:
+			query += " AND token " + op + " ?"
+			args = append(args, after.Token)
+		case dtos.SortClicks:
+			query += " AND (" + key + ", token) " + op + " (?, ?)"
+			args = append(args, after.Clicks, after.Token)
+		default:
+			query += " AND (" + key + ", token) " + op + " (?, ?)"
+			args = append(args, after.CreatedAt.UnixMilli(), after.Token)
+		}
+	}
+
+	query += " ORDER BY " + key + " " + direction + ", token " + direction
+
+	// Лишняя строка сверх лимита показывает, что за страницей есть следующая
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	var shurls []entities.ShURL
+	var lastClicks int64
+	for rows.Next() {
+		var clicks int64
+		var extra []any
+		if filter.Sort == dtos.SortClicks {
+			extra = append(extra, &clicks)
+		}
+
+		shurl, err := scanShURL(rows, extra...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if filter.Limit > 0 && len(shurls) == filter.Limit {
+			next := repository.NewShURLCursor(&shurls[len(shurls)-1], filter, lastClicks)
+			return shurls, &next, nil
+		}
+
+		shurls = append(shurls, *shurl)
+		lastClicks = clicks
 	}
+
 	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return shurls, nil, nil
+}
+
+// query - выполнить запрос, возвращающий колонки shurlSelectColumns, и считать результат
+func (r *SQLiteShURLRepository) query(ctx context.Context, query string, args ...any) ([]entities.ShURL, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
 		return nil, err
 	}
 
@@ -101,12 +349,15 @@ func (r *SQLiteShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL, e
 			return nil, err
 		}
 
-		var shurl entities.ShURL
-		err := rows.Scan(&shurl.Token, &shurl.LongURL, &shurl.CreatedBy)
+		shurl, err := scanShURL(rows)
 		if err != nil {
 			return nil, err
 		}
-		shurls = append(shurls, shurl)
+		shurls = append(shurls, *shurl)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return shurls, nil
@@ -114,54 +365,321 @@ func (r *SQLiteShURLRepository) GetAll(ctx context.Context) ([]entities.ShURL, e
 
 // Get - получить ShURL по ID (токену)
 func (r *SQLiteShURLRepository) Get(ctx context.Context, id string) (*entities.ShURL, error) {
-	var shurl entities.ShURL
+	shurl, deleted, err := r.GetWithDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted {
+		return nil, errGone
+	}
+
+	return shurl, nil
+}
+
+// GetWithDeleted - получить ShURL по ID, в том числе удалённую
+func (r *SQLiteShURLRepository) GetWithDeleted(ctx context.Context, id string) (*entities.ShURL, bool, error) {
 	var deleted bool
-	err := r.db.QueryRowContext(
+	shurl, err := scanShURL(r.db.QueryRowContext(
 		ctx,
-		"SELECT token, longurl, createdby, deleted FROM shurls WHERE token = ?",
+		"SELECT "+shurlSelectColumns+", deleted FROM shurls WHERE token = ?",
 		id,
-	).Scan(&shurl.Token, &shurl.LongURL, &shurl.CreatedBy, &deleted)
+	), &deleted)
 
-	if deleted {
-		return nil, errGone
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, errNotFound
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return &shurl, nil
+
+	return shurl, deleted, nil
 }
 
 // Create - создать ShURL
 func (r *SQLiteShURLRepository) Create(ctx context.Context, shurl *entities.ShURL) error {
-	_, err := r.db.ExecContext(
+	// Ссылка без меток вставляется одним запросом, с метками - в транзакции вместе с ними
+	if len(shurl.Tags) == 0 {
+		return insertShURL(ctx, r.db, shurl)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertShURL(ctx, tx, shurl); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateMany - создать ShURL в одной транзакции (при коллизии любого токена транзакция откатывается целиком)
+func (r *SQLiteShURLRepository) CreateMany(ctx context.Context, shurls []entities.ShURL) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range shurls {
+		if err := insertShURL(ctx, tx, &shurls[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// execer - *sql.DB или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertShURL - вставить ShURL и его метки (ссылку с метками нужно вставлять в транзакции).
+// ON CONFLICT вместо разбора кода ошибки: занятый токен (в т.ч. удалённой ссылкой) не считается ошибкой БД
+func insertShURL(ctx context.Context, db execer, shurl *entities.ShURL) error {
+	targeting, err := repository.MarshalJSONColumn(shurl.Targeting)
+	if err != nil {
+		return err
+	}
+
+	variants, err := repository.MarshalJSONColumn(shurl.Variants)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(
 		ctx,
-		"INSERT INTO shurls (token, longurl, createdby) VALUES (?, ?, ?)",
+		"INSERT INTO shurls ("+shurlColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (token) DO NOTHING",
 		shurl.Token,
 		shurl.LongURL,
 		shurl.CreatedBy,
+		toUnixMilli(shurl.ExpiresAt),
+		shurl.PasswordHash,
+		shurl.ClicksLeft,
+		toUnixMilli(shurl.NotBefore),
+		toUnixMilli(shurl.NotAfter),
+		toUnixMilli(&shurl.CreatedAt),
+		shurl.Title,
+		shurl.RedirectStatus,
+		shurl.Query.Passthrough,
+		shurl.Query.UTMSource,
+		shurl.Query.UTMMedium,
+		shurl.Query.UTMCampaign,
+		shurl.Query.Conflict,
+		targeting,
+		variants,
+		shurl.StickyVariants,
+		shurl.Folder,
 	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return repository.NewAlreadyExistsError(shurl.Token)
+	}
+
+	return insertTags(ctx, db, shurl.Token, shurl.Tags)
+}
+
+// insertTags - вставить метки ссылки одним запросом
+func insertTags(ctx context.Context, db execer, token string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 2*len(tags))
+	for _, tag := range tags {
+		args = append(args, token, tag)
+	}
+
+	placeholders := strings.Repeat("(?, ?), ", len(tags)-1) + "(?, ?)"
+	_, err := db.ExecContext(ctx, "INSERT INTO shurl_tags (token, tag) VALUES "+placeholders+" ON CONFLICT DO NOTHING", args...)
 	return err
 }
 
-// Update - обновить ShURL
+// Update - обновить ShURL (метки заменяются целиком в той же транзакции)
 func (r *SQLiteShURLRepository) Update(ctx context.Context, shurl *entities.ShURL) error {
-	_, err := r.db.ExecContext(
+	targeting, err := repository.MarshalJSONColumn(shurl.Targeting)
+	if err != nil {
+		return err
+	}
+
+	variants, err := repository.MarshalJSONColumn(shurl.Variants)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
 		ctx,
-		"UPDATE shurls SET longurl = ?, createdby = ? WHERE token = ?",
+		"UPDATE shurls SET longurl = ?, createdby = ?, expiresat = ?, passwordhash = ?, notbefore = ?, notafter = ?, title = ?, redirectstatus = ?, passquery = ?, utmsource = ?, utmmedium = ?, utmcampaign = ?, queryconflict = ?, targeting = ?, variants = ?, stickyvariants = ?, folder = ? WHERE token = ? AND deleted = FALSE",
 		shurl.LongURL,
 		shurl.CreatedBy,
+		toUnixMilli(shurl.ExpiresAt),
+		shurl.PasswordHash,
+		toUnixMilli(shurl.NotBefore),
+		toUnixMilli(shurl.NotAfter),
+		shurl.Title,
+		shurl.RedirectStatus,
+		shurl.Query.Passthrough,
+		shurl.Query.UTMSource,
+		shurl.Query.UTMMedium,
+		shurl.Query.UTMCampaign,
+		shurl.Query.Conflict,
+		targeting,
+		variants,
+		shurl.StickyVariants,
+		shurl.Folder,
 		shurl.Token,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Отсутствующая или удалённая ссылка не обновляется - как и в репозитории в памяти, это errNotFound
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM shurl_tags WHERE token = ?", shurl.Token); err != nil {
+		return err
+	}
+
+	if err := insertTags(ctx, tx, shurl.Token, shurl.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeClick - списать переход условным обновлением: из двух параллельных переходов последний доступный получит только один
+func (r *SQLiteShURLRepository) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE shurls SET clicksleft = clicksleft - 1 WHERE token = ? AND deleted = FALSE AND (clicksleft IS NULL OR clicksleft > 0)", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected > 0 {
+		return true, nil
+	}
+
+	// Строка не обновлена: переходы исчерпаны, либо ссылки нет или она удалена
+	if _, err := r.Get(ctx, id); err != nil {
+		return false, err
+	}
+
+	return false, nil
 }
 
 // Delete - удалить ShURL
 func (r *SQLiteShURLRepository) Delete(ctx context.Context, ids []string, userID string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE shurls SET deleted = TRUE WHERE token = ANY(?) AND createdby = ?", ids, userID)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// В SQLite нет массивов (ANY), поэтому список токенов передаётся через IN с отдельным параметром на каждый токен
+	args := make([]any, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, userID)
+
+	placeholders := strings.Repeat("?, ", len(ids)-1) + "?"
+	_, err := r.db.ExecContext(ctx, "UPDATE shurls SET deleted = TRUE WHERE token IN ("+placeholders+") AND createdby = ?", args...)
+	return err
+}
+
+// DeleteExpired - пометить удалёнными ShURL, срок жизни которых истёк к моменту now
+func (r *SQLiteShURLRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE shurls SET deleted = TRUE WHERE deleted = FALSE AND expiresat <= ?", now.UnixMilli())
 	return err
 }
 
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanShURL - считать ShURL из строки результата запроса (extra - колонки, следующие в запросе за shurlSelectColumns)
+func scanShURL(row rowScanner, extra ...any) (*entities.ShURL, error) {
+	var shurl entities.ShURL
+	var expiresAt, clicksLeft, notBefore, notAfter, createdAt sql.NullInt64
+	var targeting, variants, tags string
+	dest := append([]any{&shurl.Token, &shurl.LongURL, &shurl.CreatedBy, &expiresAt, &shurl.PasswordHash, &clicksLeft, &notBefore, &notAfter, &createdAt, &shurl.Title, &shurl.RedirectStatus, &shurl.Query.Passthrough, &shurl.Query.UTMSource, &shurl.Query.UTMMedium, &shurl.Query.UTMCampaign, &shurl.Query.Conflict, &targeting, &variants, &shurl.StickyVariants, &shurl.Folder, &tags}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if shurl.Targeting, err = repository.UnmarshalJSONColumn[entities.TargetingRule](targeting); err != nil {
+		return nil, err
+	}
+	if shurl.Variants, err = repository.UnmarshalJSONColumn[entities.Variant](variants); err != nil {
+		return nil, err
+	}
+	if shurl.Tags, err = repository.UnmarshalJSONColumn[string](tags); err != nil {
+		return nil, err
+	}
+
+	if t := fromUnixMilli(createdAt); t != nil {
+		shurl.CreatedAt = *t
+	}
+
+	shurl.ExpiresAt = fromUnixMilli(expiresAt)
+	shurl.NotBefore = fromUnixMilli(notBefore)
+	shurl.NotAfter = fromUnixMilli(notAfter)
+	if clicksLeft.Valid {
+		left := int(clicksLeft.Int64)
+		shurl.ClicksLeft = &left
+	}
+	return &shurl, nil
+}
+
+// toUnixMilli - преобразовать необязательное время в значение колонки (NULL для nil)
+func toUnixMilli(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+// fromUnixMilli - преобразовать значение колонки в необязательное время (nil для NULL)
+func fromUnixMilli(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+
+	t := time.UnixMilli(v.Int64)
+	return &t
+}
+
 // CloseConnection - закрыть соединение с базой данных
 func (r *SQLiteShURLRepository) CloseConnection() {
 	r.db.Close()
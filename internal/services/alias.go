@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+)
+
+// Ограничения на длину пользовательского алиаса
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+// aliasPattern - допустимые символы алиаса (безопасные для URL без экранирования)
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reservedAliases - алиасы, совпадающие с маршрутами приложения
+var reservedAliases = map[string]struct{}{
+	"ping": {},
+	"api":  {},
+}
+
+// Кастомные типы ошибок валидации алиаса
+var (
+	aliasLengthError   = customerrors.NewBadRequestError(errors.New("alias length must be between 3 and 64 characters"))
+	aliasCharsetError  = customerrors.NewBadRequestError(errors.New("alias may contain only latin letters, digits, '-' and '_'"))
+	aliasReservedError = customerrors.NewBadRequestError(errors.New("alias is reserved"))
+	aliasTakenError    = customerrors.NewAlreadyExistsError(errors.New("alias is already taken"))
+)
+
+// validateAlias - проверить формат алиаса
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return aliasLengthError
+	}
+
+	if !aliasPattern.MatchString(alias) {
+		return aliasCharsetError
+	}
+
+	if isReservedToken(alias) {
+		return aliasReservedError
+	}
+
+	return nil
+}
+
+// isReservedToken - совпадает ли токен с маршрутом приложения
+func isReservedToken(token string) bool {
+	_, reserved := reservedAliases[strings.ToLower(token)]
+	return reserved
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+)
+
+// CreateMany - создать пачку ShURL одной задачей и одним обращением к репозиторию.
+// Результаты возвращаются для каждого элемента в порядке newURLs: некорректные элементы и дубли
+// не мешают созданию остальных. Ошибка возвращается только если пачку не удалось обработать целиком
+func (s *ShURLService) CreateMany(ctx context.Context, newURLs []dtos.NewShURL) ([]dtos.BatchItemResult, error) {
+	res, err := s.enqueueTask(Task{
+		Type:    TaskCreateMany,
+		Context: ctx,
+		Payload: newURLs,
+	})
+
+	results, _ := res.([]dtos.BatchItemResult)
+	return results, err
+}
+
+// createMany - создать пачку ShURL (инкапсулирует все проверки бизнес-логику)
+func (s *ShURLService) createMany(ctx context.Context, newURLs []dtos.NewShURL) ([]dtos.BatchItemResult, error) {
+	results := make([]dtos.BatchItemResult, len(newURLs))
+
+	// Ссылки, которые нужно создать, и индексы соответствующих им элементов пачки
+	var pending []entities.ShURL
+	var pendingIdx []int
+
+	for i, newURL := range newURLs {
+		shurl, err := s.prepareBatchItem(newURL)
+		if err != nil {
+			results[i] = dtos.BatchItemResult{Status: dtos.BatchItemInvalid, Err: err}
+			continue
+		}
+
+		pending = append(pending, shurl)
+		pendingIdx = append(pendingIdx, i)
+	}
+
+	// Дубли уже существующих ссылок и повторы внутри пачки не создаются
+	pending, pendingIdx, duplicateOf, err := s.resolveBatchDuplicates(ctx, newURLs, pending, pendingIdx, results)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ссылкам без алиаса генерируем токены; номер попытки храним для каждой, чтобы при коллизии продолжить с него
+	attempts := make([]int, len(pending))
+	for j := range pending {
+		if newURLs[pendingIdx[j]].Alias != "" {
+			continue
+		}
+
+		token, attempt, err := s.generateToken(pending[j].LongURL, 0)
+		if err != nil {
+			return nil, err
+		}
+		pending[j].Token, attempts[j] = token, attempt
+	}
+
+	for len(pending) > 0 {
+		err := s.repo.CreateMany(ctx, pending)
+		if !repository.IsAlreadyExists(err) {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		// Транзакция откатилась целиком - исправляем только столкнувшийся элемент и повторяем вставку
+		var existsErr *repository.AlreadyExistsError
+		errors.As(err, &existsErr)
+
+		j := generatedTokenIndex(pending, pendingIdx, newURLs, existsErr.ID)
+		if j >= 0 {
+			s.recordTokenCollision(existsErr.ID, attempts[j])
+
+			token, attempt, err := s.generateToken(pending[j].LongURL, attempts[j]+1)
+			if err != nil {
+				return nil, err
+			}
+			pending[j].Token, attempts[j] = token, attempt
+			continue
+		}
+
+		// Занят алиас: отклоняем последний элемент с ним (если алиас повторяется в пачке, первый ещё может быть создан)
+		j = lastAliasIndex(pending, existsErr.ID)
+		if j < 0 {
+			return nil, err
+		}
+		results[pendingIdx[j]] = dtos.BatchItemResult{Status: dtos.BatchItemInvalid, Err: aliasTakenError}
+		pending = append(pending[:j], pending[j+1:]...)
+		pendingIdx = append(pendingIdx[:j], pendingIdx[j+1:]...)
+		attempts = append(attempts[:j], attempts[j+1:]...)
+	}
+
+	for j := range pending {
+		results[pendingIdx[j]] = dtos.BatchItemResult{ShURL: &pending[j], Status: dtos.BatchItemCreated}
+	}
+
+	// Повторы внутри пачки получают ссылку, созданную для первого вхождения URL
+	for i, first := range duplicateOf {
+		results[i] = results[first]
+		if results[i].Status == dtos.BatchItemCreated {
+			results[i].Status = dtos.BatchItemExisting
+		}
+	}
+
+	return results, nil
+}
+
+// prepareBatchItem - проверить элемент пачки и собрать ShURL (токен алиаса, если он задан)
+func (s *ShURLService) prepareBatchItem(newURL dtos.NewShURL) (entities.ShURL, error) {
+	longURL, err := s.normalizeLongURL(newURL.LongURL)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	if err := s.checkDestination(longURL); err != nil {
+		return entities.ShURL{}, err
+	}
+
+	expiresAt, err := s.resolveExpiresAt(newURL)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	if newURL.Alias != "" {
+		if err := validateAlias(newURL.Alias); err != nil {
+			return entities.ShURL{}, err
+		}
+	}
+
+	clicksLeft, err := resolveClicksLeft(newURL)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	notBefore, notAfter, err := s.resolveSchedule(newURL)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	passwordHash, err := hashPassword(newURL.Password)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	if err := validateTitle(newURL.Title); err != nil {
+		return entities.ShURL{}, err
+	}
+
+	if err := validateRedirectStatus(newURL.RedirectStatus); err != nil {
+		return entities.ShURL{}, err
+	}
+
+	if err := validateRedirectQuery(newURL.Query); err != nil {
+		return entities.ShURL{}, err
+	}
+
+	targeting, err := s.resolveTargeting(newURL.Targeting)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	variants, err := s.resolveVariants(newURL.Variants)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	tags, err := resolveTags(newURL.Tags)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	folder, err := resolveFolder(newURL.Folder)
+	if err != nil {
+		return entities.ShURL{}, err
+	}
+
+	return entities.ShURL{
+		Token:          newURL.Alias,
+		LongURL:        longURL,
+		CreatedBy:      newURL.CreatedBy,
+		CreatedAt:      s.now(),
+		Title:          newURL.Title,
+		RedirectStatus: newURL.RedirectStatus,
+		Query:          newURL.Query,
+		Targeting:      targeting,
+		Variants:       variants,
+		StickyVariants: newURL.StickyVariants && len(variants) > 0,
+		Tags:           tags,
+		Folder:         folder,
+		ExpiresAt:      expiresAt,
+		PasswordHash:   passwordHash,
+		ClicksLeft:     clicksLeft,
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+	}, nil
+}
+
+// resolveBatchDuplicates - исключить из создания обычные ссылки без алиаса (см. isDeduplicable), дублирующие уже существующие (им проставляется
+// результат existing) или более ранние элементы пачки (возвращаются в duplicateOf: индекс элемента -> индекс первого вхождения).
+// Вместо поиска по каждому URL ссылки пользователя загружаются одним запросом (при глобальной дедупликации - по каждому URL)
+func (s *ShURLService) resolveBatchDuplicates(
+	ctx context.Context,
+	newURLs []dtos.NewShURL,
+	pending []entities.ShURL,
+	pendingIdx []int,
+	results []dtos.BatchItemResult,
+) ([]entities.ShURL, []int, map[int]int, error) {
+	key := func(createdBy, longURL string) string {
+		if s.globalDedup {
+			return longURL
+		}
+		return createdBy + "\x00" + longURL
+	}
+
+	existing := make(map[string]entities.ShURL)
+	userLoaded := make(map[string]bool)
+	firstInBatch := make(map[string]int)
+	duplicateOf := make(map[int]int)
+
+	var kept []entities.ShURL
+	var keptIdx []int
+	for j, shurl := range pending {
+		i := pendingIdx[j]
+		if newURLs[i].Alias != "" || !isDeduplicable(&shurl) {
+			kept = append(kept, shurl)
+			keptIdx = append(keptIdx, i)
+			continue
+		}
+
+		var found []entities.ShURL
+		var err error
+		switch {
+		case s.globalDedup:
+			found, err = s.repo.GetByLongURL(ctx, shurl.LongURL)
+		case !userLoaded[shurl.CreatedBy]:
+			userLoaded[shurl.CreatedBy] = true
+			found, _, err = s.repo.GetByUserID(ctx, shurl.CreatedBy, dtos.ShURLFilter{})
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for _, f := range found {
+			if !isDeduplicable(&f) {
+				continue
+			}
+
+			if _, ok := existing[key(f.CreatedBy, f.LongURL)]; !ok {
+				existing[key(f.CreatedBy, f.LongURL)] = f
+			}
+		}
+
+		k := key(shurl.CreatedBy, shurl.LongURL)
+		if existed, ok := existing[k]; ok {
+			results[i] = dtos.BatchItemResult{ShURL: &existed, Status: dtos.BatchItemExisting}
+			continue
+		}
+
+		if first, ok := firstInBatch[k]; ok {
+			duplicateOf[i] = first
+			continue
+		}
+		firstInBatch[k] = i
+
+		kept = append(kept, shurl)
+		keptIdx = append(keptIdx, i)
+	}
+
+	return kept, keptIdx, duplicateOf, nil
+}
+
+// generatedTokenIndex - индекс создаваемой ссылки со сгенерированным токеном token (-1, если токен - алиас пользователя)
+func generatedTokenIndex(pending []entities.ShURL, pendingIdx []int, newURLs []dtos.NewShURL, token string) int {
+	for j := range pending {
+		if pending[j].Token == token && newURLs[pendingIdx[j]].Alias == "" {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// lastAliasIndex - индекс последней создаваемой ссылки с токеном token
+func lastAliasIndex(pending []entities.ShURL, token string) int {
+	for j := len(pending) - 1; j >= 0; j-- {
+		if pending[j].Token == token {
+			return j
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// Кастомные типы ошибок, связанные с ограничением количества переходов
+var (
+	clicksExhaustedError   = customerrors.NewGoneError(errors.New("shurl has reached its click limit"))
+	negativeMaxClicksError = customerrors.NewBadRequestError(errors.New("max_clicks must be positive"))
+)
+
+// resolveClicksLeft - начальное количество переходов нового ShURL (nil - без ограничения)
+func resolveClicksLeft(newURL dtos.NewShURL) (*int, error) {
+	if newURL.MaxClicks < 0 {
+		return nil, negativeMaxClicksError
+	}
+
+	if newURL.MaxClicks == 0 {
+		return nil, nil
+	}
+
+	clicksLeft := newURL.MaxClicks
+	return &clicksLeft, nil
+}
+
+// isClicksExhausted - исчерпаны ли переходы по ShURL
+func isClicksExhausted(shURL *entities.ShURL) bool {
+	return shURL.ClicksLeft != nil && *shURL.ClicksLeft <= 0
+}
+
+// ConsumeClick - списать переход по ShURL перед перенаправлением.
+// Для ссылок с ограничением списание атомарно: последний переход достаётся только одному из параллельных запросов,
+// остальные получают 410
+func (s *ShURLService) ConsumeClick(ctx context.Context, shURL *entities.ShURL) error {
+	if shURL.ClicksLeft == nil {
+		return nil
+	}
+
+	res, err := s.enqueueTask(Task{
+		Type:    TaskConsumeClick,
+		Context: ctx,
+		Payload: shURL.Token,
+	})
+	if err != nil {
+		return err
+	}
+
+	if consumed, _ := res.(bool); !consumed {
+		return clicksExhaustedError
+	}
+
+	return nil
+}
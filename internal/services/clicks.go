@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+)
+
+// Параметры буферизации событий переходов по умолчанию
+const (
+	defaultClickBufferSize    = 1024
+	defaultClickBatchSize     = 100
+	defaultClickFlushInterval = time.Second
+	clickFlushTimeout         = 10 * time.Second
+)
+
+// Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
+var (
+	statsNotAvailableError  = customerrors.NewHTTPError(errors.New("click statistics are not enabled"), http.StatusNotImplemented)
+	statsShURLNotFoundError = customerrors.NewNotFoundError(errors.New("shurl not found"))
+)
+
+// clickRecorder - неблокирующая буферизованная запись событий переходов
+// События копятся в канале и сбрасываются в хранилище пачками из фоновой горутины,
+// поэтому запись не увеличивает время ответа на редирект. При переполнении буфера события отбрасываются
+type clickRecorder struct {
+	store         repository.IClickRepository
+	events        chan entities.Click
+	batchSize     int
+	flushInterval time.Duration
+
+	mu     sync.RWMutex // защищает events от отправки после закрытия
+	closed bool
+	done   chan struct{}
+}
+
+// newClickRecorder - инициализация записи событий переходов и запуск фоновой горутины
+func newClickRecorder(store repository.IClickRepository, bufferSize, batchSize int, flushInterval time.Duration) *clickRecorder {
+	recorder := &clickRecorder{
+		store:         store,
+		events:        make(chan entities.Click, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+
+	go recorder.run()
+
+	return recorder
+}
+
+// record - поставить событие в очередь на запись (не блокируется)
+func (r *clickRecorder) record(click entities.Click) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.events <- click:
+	default:
+		// Буфер переполнен - событие отбрасывается, чтобы не задерживать редирект
+	}
+}
+
+// run - фоновая горутина, сбрасывающая события пачками по размеру или по таймеру
+func (r *clickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entities.Click, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+		defer cancel()
+
+		// Статистика переходов не критична - при ошибке пачка теряется, но редиректы продолжают работать
+		r.store.AddClicks(ctx, batch)
+		batch = make([]entities.Click, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case click, ok := <-r.events:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// shutdown - прекратить приём событий и дождаться записи накопленных
+func (r *clickRecorder) shutdown() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	<-r.done
+}
+
+// RecordClick - зарегистрировать переход по ShURL (не блокирует вызывающего)
+func (s *ShURLService) RecordClick(click entities.Click) {
+	if s.clicks == nil {
+		return
+	}
+
+	if click.Timestamp.IsZero() {
+		click.Timestamp = s.now()
+	}
+
+	s.clicks.record(click)
+}
+
+// GetClickStats - получить статистику переходов по ShURL. Доступно только создателю ссылки.
+// Статистика доступна и после истечения срока жизни (в том числе когда фоновая очистка уже пометила ссылку удалённой)
+// или исчерпания переходов, но не после удаления ссылки пользователем
+func (s *ShURLService) GetClickStats(ctx context.Context, token string, userID string) (*dtos.ClickStats, error) {
+	if s.clicks == nil {
+		return nil, statsNotAvailableError
+	}
+
+	res, err := s.enqueueTask(Task{
+		Type:    TaskGetStored,
+		Context: ctx,
+		Payload: token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shURL, ok := res.(*entities.ShURL)
+	if !ok || shURL == nil {
+		return nil, statsShURLNotFoundError
+	}
+
+	if shURL.CreatedBy != userID {
+		return nil, forbiddenError
+	}
+
+	return s.clicks.store.GetClickStats(ctx, token)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Параметры пакетного удаления по умолчанию
+const (
+	defaultDeletionBufferSize    = 1024
+	defaultDeletionBatchSize     = 100
+	defaultDeletionFlushInterval = 500 * time.Millisecond
+	deletionFlushTimeout         = 10 * time.Second
+)
+
+// deletionRequest - запрос пользователя на удаление ShURL
+type deletionRequest struct {
+	userID string
+	tokens []string
+}
+
+// deletionBatcher - асинхронное пакетное удаление ShURL.
+// Запросы всех обработчиков сливаются (fan-in) в один канал, который разбирает одна фоновая горутина:
+// токены копятся по пользователям и удаляются одним вызовом репозитория на пользователя,
+// как только накопится batchSize токенов или пройдёт flushInterval
+type deletionBatcher struct {
+	repo          repository.IRepository[entities.ShURL]
+	requests      chan deletionRequest
+	batchSize     int
+	flushInterval time.Duration
+	logger        *zap.Logger
+
+	mu     sync.RWMutex // защищает requests от отправки после закрытия
+	closed bool
+	done   chan struct{}
+}
+
+// newDeletionBatcher - инициализация пакетного удаления и запуск фоновой горутины
+func newDeletionBatcher(repo repository.IRepository[entities.ShURL], batchSize int, flushInterval time.Duration, logger *zap.Logger) *deletionBatcher {
+	batcher := &deletionBatcher{
+		repo:          repo,
+		requests:      make(chan deletionRequest, defaultDeletionBufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+		done:          make(chan struct{}),
+	}
+
+	go batcher.run()
+
+	return batcher
+}
+
+// submit - поставить запрос в очередь на удаление.
+// В отличие от событий переходов запросы не отбрасываются: при заполненном буфере вызывающий ждёт (не дольше ctx)
+func (b *deletionBatcher) submit(ctx context.Context, request deletionRequest) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return serviceUnavailableError
+	}
+
+	select {
+	case b.requests <- request:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run - фоновая горутина, накапливающая запросы и удаляющая ShURL пачками по размеру или по таймеру
+func (b *deletionBatcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[string][]string)
+	count := 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), deletionFlushTimeout)
+		defer cancel()
+
+		for userID, tokens := range pending {
+			if err := b.repo.Delete(ctx, tokens, userID); err != nil {
+				b.logger.Error("failed to delete shurls",
+					zap.String("user_id", userID),
+					zap.Int("tokens", len(tokens)),
+					zap.Error(err),
+				)
+			}
+		}
+
+		pending = make(map[string][]string)
+		count = 0
+	}
+
+	for {
+		select {
+		case request, ok := <-b.requests:
+			if !ok {
+				flush()
+				return
+			}
+
+			pending[request.userID] = append(pending[request.userID], request.tokens...)
+			count += len(request.tokens)
+			if count >= b.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// shutdown - прекратить приём запросов и удалить накопленные ShURL
+func (b *deletionBatcher) shutdown() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.requests)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// URLReasonBlocked - причина отказа: адрес назначения запрещён политикой
+const URLReasonBlocked = "destination_blocked"
+
+// Resolve - получить ShURL для перехода по нему.
+// В отличие от Get проверяет окно активности ссылки и адрес назначения по текущей политике
+// (правила могли измениться после создания ссылки)
+func (s *ShURLService) Resolve(ctx context.Context, token string) (*entities.ShURL, error) {
+	shURL, err := s.Get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSchedule(shURL, s.now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDestination(shURL.LongURL); err != nil {
+		return nil, err
+	}
+
+	return shURL, nil
+}
+
+// Destination - адрес, на который выполняется переход по shURL: адрес первого сработавшего правила,
+// без совпадений - адрес варианта A/B-теста, а у ссылки без вариантов - длинный URL.
+// К адресу применяются UTM-метки и параметры запроса. Адрес правила или варианта проверяется по текущей политике адресов назначения
+func (s *ShURLService) Destination(shURL *entities.ShURL, req dtos.RedirectRequest) (dtos.RedirectTarget, error) {
+	var result dtos.RedirectTarget
+
+	target := *shURL
+	if rule := matchTargeting(shURL.Targeting, req.Header, s.now()); rule != nil {
+		target.LongURL = rule.URL
+	} else {
+		stickyID := ""
+		if shURL.StickyVariants {
+			stickyID = req.Variant
+		}
+
+		if variant := pickVariant(shURL.Variants, stickyID); variant != nil {
+			target.LongURL = variant.URL
+			result.Variant = variant.ID
+		}
+	}
+
+	if target.LongURL != shURL.LongURL {
+		if err := s.checkDestination(target.LongURL); err != nil {
+			return dtos.RedirectTarget{}, err
+		}
+	}
+
+	result.URL = DestinationURL(&target, req.Query, req.Header.Get("Referer"))
+	return result, nil
+}
+
+// checkDestination - проверить нормализованный длинный URL по политике адресов назначения (ошибка 403 с ID сработавшего правила)
+func (s *ShURLService) checkDestination(longURL string) error {
+	if s.policy == nil {
+		return nil
+	}
+
+	parsed, err := url.Parse(longURL)
+	if err != nil {
+		return err
+	}
+
+	decision := s.policy.Evaluate(parsed.Hostname())
+	if decision.Allowed {
+		return nil
+	}
+
+	return &customerrors.HTTPError{
+		Code:    http.StatusForbidden,
+		Err:     fmt.Errorf("destination host %q is blocked by policy rule %q", parsed.Hostname(), decision.RuleID),
+		Reason:  URLReasonBlocked,
+		Details: map[string]string{"rule_id": decision.RuleID},
+	}
+}
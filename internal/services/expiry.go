@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+const (
+	// defaultExpirySweepInterval - периодичность фоновой очистки ShURL с истёкшим сроком жизни по умолчанию
+	defaultExpirySweepInterval = time.Minute
+	// expirySweepTimeout - ограничение времени одного прохода фоновой очистки
+	expirySweepTimeout = 30 * time.Second
+)
+
+// Кастомные типы ошибок, связанные со сроком жизни ShURL
+var (
+	expiredError       = customerrors.NewGoneError(errors.New("shurl has expired"))
+	deletedError       = customerrors.NewGoneError(errors.New("shurl has been deleted"))
+	ambiguousTTLError  = customerrors.NewBadRequestError(errors.New("only one of expires_at and ttl may be specified"))
+	negativeTTLError   = customerrors.NewBadRequestError(errors.New("ttl must be positive"))
+	expiresInPastError = customerrors.NewBadRequestError(errors.New("expires_at must be in the future"))
+)
+
+// resolveExpiresAt - вычислить момент истечения срока жизни нового ShURL (nil - бессрочная ссылка)
+func (s *ShURLService) resolveExpiresAt(newURL dtos.NewShURL) (*time.Time, error) {
+	if newURL.ExpiresAt != nil && newURL.TTL != 0 {
+		return nil, ambiguousTTLError
+	}
+
+	now := s.now()
+
+	if newURL.TTL != 0 {
+		if newURL.TTL < 0 {
+			return nil, negativeTTLError
+		}
+
+		expiresAt := now.Add(newURL.TTL)
+		return &expiresAt, nil
+	}
+
+	if newURL.ExpiresAt != nil {
+		if !newURL.ExpiresAt.After(now) {
+			return nil, expiresInPastError
+		}
+
+		expiresAt := *newURL.ExpiresAt
+		return &expiresAt, nil
+	}
+
+	return nil, nil
+}
+
+// isExpired - истёк ли срок жизни ShURL на момент now
+func isExpired(shURL *entities.ShURL, now time.Time) bool {
+	return shURL.ExpiresAt != nil && !now.Before(*shURL.ExpiresAt)
+}
+
+// get - получить ShURL по токену с учётом срока жизни
+func (s *ShURLService) get(ctx context.Context, token string) (*entities.ShURL, error) {
+	shURL, err := s.repo.Get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ссылка могла истечь, но ещё не быть помечена удалённой фоновой очисткой
+	if isExpired(shURL, s.now()) {
+		return nil, expiredError
+	}
+
+	if isClicksExhausted(shURL) {
+		return nil, clicksExhaustedError
+	}
+
+	return shURL, nil
+}
+
+// getStored - получить ShURL по токену без проверки срока жизни и остатка переходов.
+// Истёкшие ссылки, уже помеченные удалёнными фоновой очисткой, тоже возвращаются (удалённые пользователем - нет)
+func (s *ShURLService) getStored(ctx context.Context, token string) (*entities.ShURL, error) {
+	shURL, deleted, err := s.repo.GetWithDeleted(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted && !isExpired(shURL, s.now()) {
+		return nil, deletedError
+	}
+
+	return shURL, nil
+}
+
+// SweepExpired - пометить удалёнными все ShURL, срок жизни которых истёк
+func (s *ShURLService) SweepExpired(ctx context.Context) error {
+	_, err := s.enqueueTask(Task{
+		Type:    TaskDeleteExpired,
+		Context: ctx,
+		Payload: s.now(),
+	})
+
+	return err
+}
+
+// expirySweeper - фоновая периодическая очистка ShURL с истёкшим сроком жизни
+func (s *ShURLService) expirySweeper() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(s.expirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopBackground:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), expirySweepTimeout)
+			s.SweepExpired(ctx)
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// Причины отказа при некорректных параметрах списка ссылок пользователя
+const (
+	URLReasonInvalidSort   = "invalid_sort"
+	URLReasonInvalidLimit  = "invalid_limit"
+	URLReasonInvalidCursor = "invalid_cursor"
+)
+
+// MaxPageSize - максимальное количество ссылок на одной странице списка
+const MaxPageSize = 1000
+
+// Кастомные ошибки параметров списка ссылок пользователя
+var (
+	invalidSortError   = customerrors.NewValidationError(URLReasonInvalidSort, errors.New("sort must be one of: created, clicks, alias"))
+	invalidLimitError  = customerrors.NewValidationError(URLReasonInvalidLimit, errors.New("limit must be from 1 to 1000"))
+	invalidCursorError = customerrors.NewValidationError(URLReasonInvalidCursor, errors.New("cursor does not match the requested sort order"))
+)
+
+// userShURLsPage - результат задачи TaskGetByUserID
+type userShURLsPage struct {
+	shURLs []entities.ShURL
+	next   *dtos.ShURLCursor
+}
+
+// resolveFilter - проверить параметры списка ссылок и привести их к тому виду, в котором они передаются в репозиторий
+func resolveFilter(filter dtos.ShURLFilter) (dtos.ShURLFilter, error) {
+	resolved := normalizeFilter(filter)
+	resolved.Search = strings.TrimSpace(filter.Search)
+	resolved.Desc = filter.Desc
+	resolved.Limit = filter.Limit
+	resolved.After = filter.After
+
+	switch resolved.Sort = filter.Sort; resolved.Sort {
+	case "":
+		resolved.Sort = dtos.SortCreated
+	case dtos.SortCreated, dtos.SortClicks, dtos.SortAlias:
+	default:
+		return dtos.ShURLFilter{}, invalidSortError
+	}
+
+	if err := validateLimit(resolved.Limit); err != nil {
+		return dtos.ShURLFilter{}, err
+	}
+
+	// Курсор хранит значения ключа сортировки, поэтому годится только для того же порядка
+	if after := resolved.After; after != nil && (after.Sort != resolved.Sort || after.Desc != resolved.Desc) {
+		return dtos.ShURLFilter{}, invalidCursorError
+	}
+
+	return resolved, nil
+}
+
+// ParseLimit - разобрать размер страницы списка ссылок, переданный строкой (например, в параметре запроса)
+func ParseLimit(value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit == 0 {
+		return 0, invalidLimitError
+	}
+
+	return limit, validateLimit(limit)
+}
+
+// validateLimit - проверить размер страницы списка ссылок (0 - без ограничения)
+func validateLimit(limit int) error {
+	if limit < 0 || limit > MaxPageSize {
+		return invalidLimitError
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"golang.org/x/net/idna"
+)
+
+// defaultMaxURLLength - максимальная длина длинного URL по умолчанию
+const defaultMaxURLLength = 2048
+
+// Машиночитаемые причины отклонения длинного URL (поле reason ответа 400)
+const (
+	URLReasonEmpty       = "empty_url"
+	URLReasonTooLong     = "url_too_long"
+	URLReasonMalformed   = "malformed_url"
+	URLReasonScheme      = "unsupported_scheme"
+	URLReasonMissingHost = "missing_host"
+	URLReasonInvalidHost = "invalid_host"
+)
+
+// Кастомные типы ошибок проверки длинного URL
+var (
+	emptyLongURLError   = customerrors.NewValidationError(URLReasonEmpty, errors.New("url is empty"))
+	urlTooLongError     = customerrors.NewValidationError(URLReasonTooLong, errors.New("url is too long"))
+	malformedURLError   = customerrors.NewValidationError(URLReasonMalformed, errors.New("url is malformed"))
+	urlSchemeError      = customerrors.NewValidationError(URLReasonScheme, errors.New("only http and https urls are allowed"))
+	urlMissingHostError = customerrors.NewValidationError(URLReasonMissingHost, errors.New("url has no host"))
+	urlInvalidHostError = customerrors.NewValidationError(URLReasonInvalidHost, errors.New("url host is invalid"))
+)
+
+// asciiHostPattern - допустимые символы ASCII-имени хоста (подчёркивание встречается в реальных именах, поэтому допускается)
+var asciiHostPattern = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-z0-9_-]+)*\.?$`)
+
+// defaultPorts - порты по умолчанию для допустимых схем
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// URLNormalization - настройки приведения длинного URL к каноническому виду
+type URLNormalization struct {
+	StripDefaultPort bool // убирать порт по умолчанию для схемы (:80 для http, :443 для https)
+	StripFragment    bool // убирать фрагмент (#...)
+	MaxLength        int  // максимальная длина URL в байтах (0 - defaultMaxURLLength)
+}
+
+// normalizeLongURL - проверить длинный URL и привести его к каноническому виду:
+// схема и хост в нижнем регистре, IDN-хост в punycode, при настройке - без порта по умолчанию и фрагмента
+func (s *ShURLService) normalizeLongURL(raw string) (string, error) {
+	maxLength := s.urlNormalization.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxURLLength
+	}
+
+	longURL := strings.TrimSpace(raw)
+	if longURL == "" {
+		return "", emptyLongURLError
+	}
+
+	if len(longURL) > maxLength {
+		return "", urlTooLongError
+	}
+
+	// Пробелы и управляющие символы внутри URL должны быть экранированы
+	if strings.IndexFunc(longURL, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", malformedURLError
+	}
+
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return "", malformedURLError
+	}
+
+	// url.Parse приводит схему к нижнему регистру; "javascript:", "data:" и URL без схемы отклоняются
+	if _, ok := defaultPorts[u.Scheme]; !ok {
+		return "", urlSchemeError
+	}
+
+	if u.Opaque != "" {
+		return "", malformedURLError
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if s.urlNormalization.StripDefaultPort && port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if s.urlNormalization.StripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	// Punycode может удлинить URL, поэтому длина проверяется и после нормализации
+	longURL = u.String()
+	if len(longURL) > maxLength {
+		return "", urlTooLongError
+	}
+
+	return longURL, nil
+}
+
+// normalizeHost - проверить имя хоста и привести его к нижнему регистру (IDN - к punycode)
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", urlMissingHostError
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return strings.ToLower(host), nil
+	}
+
+	// Строгий профиль IDNA применяем только к интернационализированным именам,
+	// чтобы не отклонять встречающиеся на практике ASCII-имена с подчёркиванием
+	if !isASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", urlInvalidHostError
+		}
+		host = ascii
+	}
+
+	host = strings.ToLower(host)
+	if !asciiHostPattern.MatchString(host) {
+		return "", urlInvalidHostError
+	}
+
+	return host, nil
+}
+
+// isASCII - состоит ли строка только из ASCII-символов
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
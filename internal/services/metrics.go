@@ -0,0 +1,27 @@
+package services
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+)
+
+// Метрики сервиса (публикуются только через MetricsHandler: в общий реестр expvar не попадают,
+// чтобы не открывать вместе с ними cmdline и memstats)
+var (
+	// tokenCollisions - количество сгенерированных токенов, оказавшихся уже занятыми
+	tokenCollisions = new(expvar.Int)
+	// tokenAttemptsExhausted - количество созданий ShURL, для которых не удалось подобрать свободный токен
+	tokenAttemptsExhausted = new(expvar.Int)
+)
+
+// MetricsHandler - обработчик, отдающий метрики сервиса в формате JSON
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]int64{
+			"shurl_token_collisions_total":         tokenCollisions.Value(),
+			"shurl_token_attempts_exhausted_total": tokenAttemptsExhausted.Value(),
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/policy"
+	"github.com/JustScorpio/urlshortener/internal/repository"
+	"github.com/JustScorpio/urlshortener/internal/tokengen"
+	"go.uber.org/zap"
+)
+
+// ShURLServiceOption - необязательный параметр конфигурации ShURLService
+type ShURLServiceOption func(*ShURLService)
+
+// WithClock - задать источник текущего времени (используется в тестах для управления временем)
+func WithClock(now func() time.Time) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.now = now
+	}
+}
+
+// WithExpirySweepInterval - задать периодичность удаления ShURL с истёкшим сроком жизни (0 - не запускать фоновую очистку)
+func WithExpirySweepInterval(interval time.Duration) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.expirySweepInterval = interval
+	}
+}
+
+// WithClickStore - включить запись событий переходов в хранилище store
+func WithClickStore(store repository.IClickRepository) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.clickStore = store
+	}
+}
+
+// WithGlobalDeduplication - искать дубли длинного URL среди ссылок всех пользователей (по умолчанию - только среди ссылок создателя)
+func WithGlobalDeduplication(enabled bool) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.globalDedup = enabled
+	}
+}
+
+// WithTokenGenerator - задать стратегию генерации токенов (по умолчанию - случайные 8 латинских букв)
+func WithTokenGenerator(generator tokengen.TokenGenerator) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.tokens = generator
+	}
+}
+
+// WithLogger - задать логгер сервиса (по умолчанию логи не пишутся)
+func WithLogger(logger *zap.Logger) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.logger = logger
+	}
+}
+
+// WithWorkers - задать количество воркеров, обрабатывающих задачи сервиса (по умолчанию - GOMAXPROCS).
+// n воркеров параллельно обрабатывают чтения и ещё n - записи, распределённые по ключу упорядочивания
+func WithWorkers(n int) ShURLServiceOption {
+	return func(s *ShURLService) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// WithDeletionBatching - задать параметры пакетного удаления: накопленные запросы удаляются,
+// как только наберётся batchSize токенов или пройдёт flushInterval
+func WithDeletionBatching(batchSize int, flushInterval time.Duration) ShURLServiceOption {
+	return func(s *ShURLService) {
+		if batchSize > 0 {
+			s.deletionBatchSize = batchSize
+		}
+		if flushInterval > 0 {
+			s.deletionFlushInterval = flushInterval
+		}
+	}
+}
+
+// WithURLNormalization - задать настройки приведения длинных URL к каноническому виду
+func WithURLNormalization(normalization URLNormalization) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.urlNormalization = normalization
+	}
+}
+
+// WithDestinationPolicy - проверять адреса назначения по политике engine при создании, изменении и переходе.
+// Файл правил перечитывается каждые reloadInterval (0 - не перечитывать)
+func WithDestinationPolicy(engine *policy.Engine, reloadInterval time.Duration) ShURLServiceOption {
+	return func(s *ShURLService) {
+		s.policy = engine
+		s.policyReloadInterval = reloadInterval
+	}
+}
+
+// WithPasswordAttemptLimit - задать ограничение неудачных попыток ввода пароля защищённой ссылки:
+// не более maxFailures за window для каждой ссылки (по умолчанию - 5 в минуту)
+func WithPasswordAttemptLimit(maxFailures int, window time.Duration) ShURLServiceOption {
+	return func(s *ShURLService) {
+		if maxFailures > 0 && window > 0 {
+			s.passwordAttempts = newAttemptLimiter(maxFailures, window)
+		}
+	}
+}
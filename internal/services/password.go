@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// URLReasonPasswordTooLong - причина отказа: пароль длиннее, чем поддерживает bcrypt
+const URLReasonPasswordTooLong = "password_too_long"
+
+// PasswordReasonTooManyAttempts - причина отказа: превышено количество неудачных попыток ввода пароля
+const PasswordReasonTooManyAttempts = "too_many_attempts"
+
+// maxPasswordLength - максимальная длина пароля в байтах (bcrypt учитывает только первые 72 байта)
+const maxPasswordLength = 72
+
+// Ограничение неудачных попыток ввода пароля по умолчанию
+const (
+	defaultPasswordMaxFailures = 5
+	defaultPasswordFailWindow  = time.Minute
+)
+
+// Кастомные типы ошибок проверки пароля
+var (
+	passwordTooLongError = customerrors.NewValidationError(URLReasonPasswordTooLong, errors.New("password must not exceed 72 bytes"))
+	wrongPasswordError   = customerrors.NewUnauthorizedError(errors.New("wrong password"))
+)
+
+// hashPassword - получить bcrypt-хеш пароля новой ссылки ("" - ссылка без пароля)
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	if len(password) > maxPasswordLength {
+		return "", passwordTooLongError
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Unlock - проверить пароль защищённой ссылки и получить ShURL для перехода по нему.
+// Неудачные попытки ограничиваются для каждой ссылки: после их исчерпания возвращается 429 до конца окна.
+// Для ссылок без пароля пароль не проверяется
+func (s *ShURLService) Unlock(ctx context.Context, token, password string) (*entities.ShURL, error) {
+	shURL, err := s.Resolve(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if shURL.PasswordHash == "" {
+		return shURL, nil
+	}
+
+	// Попытка засчитывается до сравнения: иначе параллельные запросы успевают проверить больше паролей, чем разрешено.
+	// bcrypt намеренно медленный, поэтому сравнение выполняется вне воркеров сервиса
+	if retryAfter, ok := s.passwordAttempts.reserve(token, s.now()); !ok {
+		return nil, tooManyAttemptsError(retryAfter)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(shURL.PasswordHash), []byte(password)); err != nil {
+		return nil, wrongPasswordError
+	}
+
+	s.passwordAttempts.reset(token)
+	return shURL, nil
+}
+
+// tooManyAttemptsError - ошибка 429 со временем до следующей попытки (в секундах, Details["retry_after"])
+func tooManyAttemptsError(retryAfter time.Duration) error {
+	return &customerrors.HTTPError{
+		Code:    http.StatusTooManyRequests,
+		Err:     fmt.Errorf("too many wrong passwords, retry in %s", retryAfter.Round(time.Second)),
+		Reason:  PasswordReasonTooManyAttempts,
+		Details: map[string]string{"retry_after": strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))},
+	}
+}
+
+// attemptLimiter - ограничение неудачных попыток для каждого ключа (токена) в фиксированном окне
+type attemptLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	failures    map[string]*attemptWindow
+}
+
+// attemptWindow - неудачные попытки в текущем окне
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+// maxTrackedAttemptKeys - количество ключей, после которого при очередной попытке вычищаются истёкшие окна
+const maxTrackedAttemptKeys = 10000
+
+// newAttemptLimiter - инициализация ограничителя попыток
+func newAttemptLimiter(maxFailures int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string]*attemptWindow),
+	}
+}
+
+// reserve - засчитать попытку для key в момент now, если лимит окна не исчерпан (если исчерпан - через сколько можно повторить).
+// Попытка считается неудачной, пока не вызван reset
+func (l *attemptLimiter) reserve(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.failures[key]
+	if ok && now.Sub(w.start) < l.window && w.count >= l.maxFailures {
+		return w.start.Add(l.window).Sub(now), false
+	}
+
+	if len(l.failures) >= maxTrackedAttemptKeys {
+		for k, w := range l.failures {
+			if now.Sub(w.start) >= l.window {
+				delete(l.failures, k)
+			}
+		}
+	}
+
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &attemptWindow{start: now}
+		l.failures[key] = w
+	}
+	w.count++
+
+	return 0, true
+}
+
+// reset - сбросить неудачные попытки для key (после успешной)
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, key)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// Политики разрешения конфликта параметров адреса назначения и добавляемых при переходе параметров
+const (
+	QueryConflictKeep     = "keep"     // оставить имеющийся параметр (по умолчанию)
+	QueryConflictOverride = "override" // заменить имеющийся параметр
+)
+
+// Подстановки в шаблонах UTM-меток
+const (
+	utmPlaceholderToken    = "{token}"    // токен короткой ссылки
+	utmPlaceholderReferrer = "{referrer}" // хост, с которого выполнен переход ("" - переход без Referer)
+)
+
+// maxUTMLength - максимальная длина шаблона UTM-метки в символах
+const maxUTMLength = 256
+
+// Причины отказа при проверке правил формирования query-строки
+const (
+	URLReasonInvalidQueryConflict = "invalid_query_conflict"
+	URLReasonUTMTooLong           = "utm_too_long"
+)
+
+// Кастомные типы ошибок проверки правил формирования query-строки
+var (
+	invalidQueryConflictError = customerrors.NewValidationError(URLReasonInvalidQueryConflict, errors.New("query conflict policy must be keep or override"))
+	utmTooLongError           = customerrors.NewValidationError(URLReasonUTMTooLong, errors.New("utm template must not exceed 256 characters"))
+)
+
+// validateRedirectQuery - проверить правила формирования query-строки новой ссылки
+func validateRedirectQuery(query entities.RedirectQuery) error {
+	switch query.Conflict {
+	case "", QueryConflictKeep, QueryConflictOverride:
+	default:
+		return invalidQueryConflictError
+	}
+
+	for _, tmpl := range []string{query.UTMSource, query.UTMMedium, query.UTMCampaign} {
+		if utf8.RuneCountInString(tmpl) > maxUTMLength {
+			return utmTooLongError
+		}
+	}
+
+	return nil
+}
+
+// UsesReferrer - зависит ли адрес назначения от заголовка Referer запроса (шаблоны UTM-меток с подстановкой {referrer})
+func UsesReferrer(query entities.RedirectQuery) bool {
+	for _, tmpl := range []string{query.UTMSource, query.UTMMedium, query.UTMCampaign} {
+		if strings.Contains(tmpl, utmPlaceholderReferrer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DestinationURL - адрес, на который выполняется переход по shURL.
+// К длинному URL добавляются UTM-метки из шаблонов ссылки, а затем (если включено) параметры incoming запроса к короткой ссылке.
+// Совпадающие параметры разрешаются политикой ссылки: keep оставляет параметр, добавленный раньше (в первую очередь - параметр длинного URL),
+// override - добавленный позже. Исходная запись параметров длинного URL не перекодируется
+func DestinationURL(shURL *entities.ShURL, incoming url.Values, referrer string) string {
+	rules := shURL.Query
+	override := rules.Conflict == QueryConflictOverride
+
+	added := make(url.Values)
+
+	replacer := strings.NewReplacer(utmPlaceholderToken, shURL.Token, utmPlaceholderReferrer, referrerHost(referrer))
+	for _, utm := range []struct{ name, tmpl string }{
+		{"utm_source", rules.UTMSource},
+		{"utm_medium", rules.UTMMedium},
+		{"utm_campaign", rules.UTMCampaign},
+	} {
+		if value := replacer.Replace(utm.tmpl); value != "" {
+			added.Set(utm.name, value)
+		}
+	}
+
+	if rules.Passthrough {
+		for name, values := range incoming {
+			if _, exists := added[name]; exists && !override {
+				continue
+			}
+			added[name] = values
+		}
+	}
+
+	if len(added) == 0 {
+		return shURL.LongURL
+	}
+
+	destination, err := url.Parse(shURL.LongURL)
+	if err != nil {
+		return shURL.LongURL
+	}
+
+	// Параметры длинного URL сохраняются в исходной записи, из них только удаляются заменяемые
+	var pairs []string
+	if destination.RawQuery != "" {
+		for _, pair := range strings.Split(destination.RawQuery, "&") {
+			name := queryParamName(pair)
+			if _, conflicts := added[name]; conflicts {
+				if override {
+					continue
+				}
+				added.Del(name)
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if encoded := added.Encode(); encoded != "" {
+		pairs = append(pairs, encoded)
+	}
+
+	destination.RawQuery = strings.Join(pairs, "&")
+	return destination.String()
+}
+
+// queryParamName - декодированное имя параметра из пары "имя=значение" query-строки
+func queryParamName(pair string) string {
+	name, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+
+	return name
+}
+
+// referrerHost - хост из заголовка Referer ("" - заголовка нет или он некорректен)
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Hostname()
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+)
+
+// URLReasonInvalidRedirectStatus - причина отказа: недопустимый код ответа при переходе по ссылке
+const URLReasonInvalidRedirectStatus = "invalid_redirect_status"
+
+// invalidRedirectStatusError - кастомная ошибка недопустимого кода ответа при переходе
+var invalidRedirectStatusError = customerrors.NewValidationError(URLReasonInvalidRedirectStatus, errors.New("redirect status must be one of 301, 302, 307, 308"))
+
+// IsRedirectStatus - является ли code допустимым кодом ответа при переходе по ссылке (301, 302, 307, 308)
+func IsRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateRedirectStatus - проверить код ответа новой ссылки (0 - код по умолчанию сервера)
+func validateRedirectStatus(code int) error {
+	if code != 0 && !IsRedirectStatus(code) {
+		return invalidRedirectStatusError
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// LinkReasonNotYetActive - причина отказа: окно активности ссылки ещё не началось (момент начала - в Details["not_before"])
+const LinkReasonNotYetActive = "not_yet_active"
+
+// Кастомные типы ошибок, связанные с окном активности ShURL
+var (
+	noLongerActiveError = customerrors.NewGoneError(errors.New("shurl is no longer active"))
+	emptyScheduleError  = customerrors.NewBadRequestError(errors.New("not_after must be later than not_before"))
+	notAfterInPastError = customerrors.NewBadRequestError(errors.New("not_after must be in the future"))
+)
+
+// resolveSchedule - проверить окно активности нового ShURL (nil - без ограничения с соответствующей стороны)
+func (s *ShURLService) resolveSchedule(newURL dtos.NewShURL) (*time.Time, *time.Time, error) {
+	if newURL.NotAfter != nil {
+		if !newURL.NotAfter.After(s.now()) {
+			return nil, nil, notAfterInPastError
+		}
+
+		if newURL.NotBefore != nil && !newURL.NotAfter.After(*newURL.NotBefore) {
+			return nil, nil, emptyScheduleError
+		}
+	}
+
+	return copyTime(newURL.NotBefore), copyTime(newURL.NotAfter), nil
+}
+
+// checkSchedule - проверить, что ShURL активна на момент now.
+// До начала окна возвращается 404 (ссылка как будто ещё не существует), после окончания - 410
+func checkSchedule(shURL *entities.ShURL, now time.Time) error {
+	if shURL.NotBefore != nil && now.Before(*shURL.NotBefore) {
+		return &customerrors.HTTPError{
+			Code:    http.StatusNotFound,
+			Err:     errors.New("shurl is not active yet"),
+			Reason:  LinkReasonNotYetActive,
+			Details: map[string]string{"not_before": shURL.NotBefore.UTC().Format(time.RFC3339)},
+		}
+	}
+
+	if shURL.NotAfter != nil && !now.Before(*shURL.NotAfter) {
+		return noLongerActiveError
+	}
+
+	return nil
+}
+
+// copyTime - копия необязательного времени (ShURL не должна разделять значение с dto запроса)
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	c := *t
+	return &c
+}
@@ -5,24 +5,58 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
 	"github.com/JustScorpio/urlshortener/internal/models/dtos"
 	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/policy"
 	"github.com/JustScorpio/urlshortener/internal/repository"
-	"github.com/jaevor/go-nanoid"
+	"github.com/JustScorpio/urlshortener/internal/tokengen"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 // ShURLService - сервис-укорачиватель ссылок
 type ShURLService struct {
 	//ВАЖНО: В Go интерфейсы УЖЕ ЯВЛЯЮТСЯ ССЫЛОЧНЫМ ТИПОМ (под капотом — указатель на структуру)
 	repo           repository.IRepository[entities.ShURL]
-	taskQueue      chan Task // канал-очередь задач
+	readQueue      chan Task   // очередь задач чтения, разбираемая всеми воркерами чтения параллельно
+	writeQueues    []chan Task // очереди задач записи: задачи с одинаковым ключом упорядочивания попадают в одну очередь
+	workers        int         // количество воркеров чтения и очередей записи
+	workersDone    sync.WaitGroup
 	tasksInProcess sync.WaitGroup
 	isShuttingDown atomic.Bool //Использование вместо Bool помогает избежать гонки данных при её обновлении
+
+	now                 func() time.Time // источник текущего времени (подменяется в тестах)
+	expirySweepInterval time.Duration
+	stopBackground      chan struct{} // сигнал остановки фоновых горутин
+	backgroundTasks     sync.WaitGroup
+
+	clickStore repository.IClickRepository
+	clicks     *clickRecorder // nil - статистика переходов не ведётся
+
+	deletions             *deletionBatcher
+	deletionBatchSize     int
+	deletionFlushInterval time.Duration
+
+	urlNormalization URLNormalization
+
+	policy               *policy.Engine // nil - адреса назначения не ограничиваются
+	policyReloadInterval time.Duration
+
+	passwordAttempts *attemptLimiter // ограничение неудачных попыток ввода пароля защищённых ссылок
+
+	globalDedup bool // искать дубли длинного URL среди ссылок всех пользователей, а не только создателя
+
+	tokens tokengen.TokenGenerator
+
+	logger *zap.Logger
 }
 
+// maxTokenAttempts - максимальное количество попыток сгенерировать незанятый токен
+const maxTokenAttempts = 16
+
 // TaskType - алиас вокруг int, для описания типов задачи в очереди задач
 type TaskType int
 
@@ -32,8 +66,13 @@ const (
 	TaskGet
 	TaskCreate
 	TaskUpdate
+	// Deprecated: удаление выполняется пакетно вне очереди задач (см. Delete); константа сохранена, чтобы не сдвигать значения следующих типов
 	TaskDelete
 	TaskGetByUserID
+	TaskDeleteExpired
+	TaskCreateMany
+	TaskConsumeClick
+	TaskGetStored
 )
 
 // Task - задача в очереди задач на обработку сервисом
@@ -54,23 +93,62 @@ type TaskResult struct {
 var (
 	alreadyExistsError      = customerrors.NewAlreadyExistsError(errors.New("shurl already exists"))
 	serviceUnavailableError = customerrors.NewServiceUnavailableError(errors.New("service is shutting down..."))
+	forbiddenError          = customerrors.NewForbiddenError(errors.New("shurl belongs to another user"))
+	tokenExhaustedError     = errors.New("failed to generate a unique token")
 )
 
 // NewShURLService - инициализация сервиса-укорачивателя ссылок
-func NewShURLService(repo repository.IRepository[entities.ShURL]) *ShURLService {
+func NewShURLService(repo repository.IRepository[entities.ShURL], opts ...ShURLServiceOption) *ShURLService {
 	service := &ShURLService{
-		repo:      repo,
-		taskQueue: make(chan Task, 300),
+		repo:                repo,
+		workers:             defaultWorkers(),
+		now:                 time.Now,
+		expirySweepInterval: defaultExpirySweepInterval,
+		stopBackground:      make(chan struct{}),
+		logger:              zap.NewNop(),
+		passwordAttempts:    newAttemptLimiter(defaultPasswordMaxFailures, defaultPasswordFailWindow),
+
+		deletionBatchSize:     defaultDeletionBatchSize,
+		deletionFlushInterval: defaultDeletionFlushInterval,
 	}
 
-	go service.taskProcessor()
+	for _, opt := range opts {
+		opt(service)
+	}
+
+	if service.tokens == nil {
+		service.tokens, _ = tokengen.NewRandomGenerator(tokengen.DefaultAlphabet, tokengen.DefaultLength)
+	}
+
+	service.deletions = newDeletionBatcher(service.repo, service.deletionBatchSize, service.deletionFlushInterval, service.logger)
+
+	if service.clickStore != nil {
+		service.clicks = newClickRecorder(service.clickStore, defaultClickBufferSize, defaultClickBatchSize, defaultClickFlushInterval)
+	}
+
+	service.startWorkers()
+
+	if service.expirySweepInterval > 0 {
+		service.backgroundTasks.Add(1)
+		go service.expirySweeper()
+	}
+
+	if service.policy != nil && service.policyReloadInterval > 0 {
+		service.backgroundTasks.Add(1)
+		go func() {
+			defer service.backgroundTasks.Done()
+			service.policy.Watch(service.stopBackground, service.policyReloadInterval, service.logger)
+		}()
+	}
 
 	return service
 }
 
-// taskProcessor - обработчик очереди задач в составе ShURLService
-func (s *ShURLService) taskProcessor() {
-	for task := range s.taskQueue {
+// taskProcessor - обработчик очереди задач в составе ShURLService (один воркер пула)
+func (s *ShURLService) taskProcessor(queue <-chan Task) {
+	defer s.workersDone.Done()
+
+	for task := range queue {
 		var result interface{}
 		var err error
 
@@ -91,32 +169,38 @@ func (s *ShURLService) taskProcessor() {
 			result, err = s.repo.GetAll(task.Context)
 		case TaskGet:
 			token := task.Payload.(string)
-			result, err = s.repo.Get(task.Context, token)
+			result, err = s.get(task.Context, token)
 		case TaskCreate:
 			shURL := task.Payload.(*dtos.NewShURL)
 			result, err = s.create(task.Context, *shURL)
+		case TaskCreateMany:
+			newURLs := task.Payload.([]dtos.NewShURL)
+			result, err = s.createMany(task.Context, newURLs)
 		case TaskUpdate:
-			shURL := task.Payload.(*entities.ShURL)
-			err = s.repo.Update(task.Context, shURL)
-		case TaskDelete:
-			payload := task.Payload.(struct {
-				userID string
-				tokens []string
-			})
-			err = s.repo.Delete(task.Context, payload.tokens, payload.userID)
+			update := task.Payload.(*dtos.UpdateShURL)
+			result, err = s.update(task.Context, *update)
 		case TaskGetByUserID:
-			userID := task.Payload.(string)
-			result, err = s.getAllByUserID(task.Context, userID)
+			query := task.Payload.(*userShURLsQuery)
+			result, err = s.getAllByUserID(task.Context, query.userID, query.filter)
+		case TaskDeleteExpired:
+			now := task.Payload.(time.Time)
+			err = s.repo.DeleteExpired(task.Context, now)
+		case TaskConsumeClick:
+			token := task.Payload.(string)
+			result, err = s.repo.ConsumeClick(task.Context, token)
+		case TaskGetStored:
+			token := task.Payload.(string)
+			result, err = s.getStored(task.Context, token)
 		}
 
 		if task.ResultCh != nil {
 			switch task.Type {
-			case TaskGetAll, TaskGet, TaskGetByUserID, TaskCreate:
+			case TaskGetAll, TaskGet, TaskGetByUserID, TaskCreate, TaskCreateMany, TaskUpdate, TaskConsumeClick, TaskGetStored:
 				task.ResultCh <- TaskResult{
 					Result: result,
 					Err:    err,
 				}
-			case TaskUpdate, TaskDelete:
+			case TaskDeleteExpired:
 				task.ResultCh <- TaskResult{
 					Err: err,
 				}
@@ -138,7 +222,7 @@ func (s *ShURLService) enqueueTask(task Task) (interface{}, error) {
 	}
 
 	s.tasksInProcess.Add(1) // Увеличиваем счетчик
-	s.taskQueue <- task
+	s.queueFor(task) <- task
 
 	select {
 	case <-task.Context.Done():
@@ -157,7 +241,8 @@ func (s *ShURLService) GetAll(ctx context.Context) ([]entities.ShURL, error) {
 		Context: ctx,
 	})
 
-	return res.([]entities.ShURL), err
+	shURLs, _ := res.([]entities.ShURL)
+	return shURLs, err
 }
 
 // Get - получить ShURL по ID (токену)
@@ -168,7 +253,8 @@ func (s *ShURLService) Get(ctx context.Context, token string) (*entities.ShURL,
 		Payload: token,
 	})
 
-	return res.(*entities.ShURL), err
+	shURL, _ := res.(*entities.ShURL)
+	return shURL, err
 }
 
 // Create - создать ShURL
@@ -179,99 +265,304 @@ func (s *ShURLService) Create(ctx context.Context, newURL dtos.NewShURL) (*entit
 		Payload: &newURL,
 	})
 
-	return res.(*entities.ShURL), err
+	shURL, _ := res.(*entities.ShURL)
+	return shURL, err
 }
 
-// Update - обновить ShURL
-// func (s *ShURLService) Update(ctx context.Context, shurl *entities.ShURL) error {
-// 	_, err := s.enqueueTask(Task{
-// 		Type:    TaskUpdate,
-// 		Context: ctx,
-// 		Payload: shurl,
-// 	})
+// Update - изменить ShURL (доступно только создателю ссылки)
+func (s *ShURLService) Update(ctx context.Context, update dtos.UpdateShURL) (*entities.ShURL, error) {
+	res, err := s.enqueueTask(Task{
+		Type:    TaskUpdate,
+		Context: ctx,
+		Payload: &update,
+	})
 
-// 	return err
-// }
+	shURL, _ := res.(*entities.ShURL)
+	return shURL, err
+}
 
-// Delete - удалить ShURL
+// Delete - удалить ShURL.
+// Удаление асинхронное: запрос ставится в очередь и выполняется пачкой вместе с запросами других пользователей.
+// Удаляются только ShURL, созданные userID
 func (s *ShURLService) Delete(ctx context.Context, tokens []string, userID string) error {
-	_, err := s.enqueueTask(Task{
-		Type:    TaskDelete,
-		Context: ctx,
-		Payload: struct {
-			userID string
-			tokens []string
-		}{userID, tokens},
-	})
+	if s.isShuttingDown.Load() {
+		return serviceUnavailableError
+	}
 
-	return err
+	return s.deletions.submit(ctx, deletionRequest{userID: userID, tokens: tokens})
 }
 
-// GetAllShURLsByUserID - получить все ShURL конкретного пользователя
-func (s *ShURLService) GetAllShURLsByUserID(ctx context.Context, userID string) ([]entities.ShURL, error) {
+// userShURLsQuery - параметры задачи TaskGetByUserID
+type userShURLsQuery struct {
+	userID string
+	filter dtos.ShURLFilter
+}
+
+// GetAllShURLsByUserID - получить страницу ShURL конкретного пользователя, удовлетворяющих фильтру
+// (метки и папка фильтра сравниваются так же, как при создании ссылки: метки - без учёта регистра).
+// Возвращает курсор следующей страницы (nil - страница последняя)
+func (s *ShURLService) GetAllShURLsByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) ([]entities.ShURL, *dtos.ShURLCursor, error) {
 	res, err := s.enqueueTask(Task{
 		Type:    TaskGetByUserID,
 		Context: ctx,
-		Payload: userID,
+		Payload: &userShURLsQuery{userID: userID, filter: filter},
 	})
 
-	return res.([]entities.ShURL), err
+	page, _ := res.(*userShURLsPage)
+	if page == nil {
+		return nil, nil, err
+	}
+
+	return page.shURLs, page.next, err
 }
 
 // create - создать ShURL (инкапсулирует все проверки бизнес-логику)
 func (s *ShURLService) create(ctx context.Context, newURL dtos.NewShURL) (*entities.ShURL, error) {
-	// Проверка наличие урла в БД
-	existedURLs, err := s.repo.GetAll(ctx)
+	longURL, err := s.normalizeLongURL(newURL.LongURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDestination(longURL); err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := s.resolveExpiresAt(newURL)
+	if err != nil {
+		return nil, err
+	}
+
+	clicksLeft, err := resolveClicksLeft(newURL)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore, notAfter, err := s.resolveSchedule(newURL)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := hashPassword(newURL.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTitle(newURL.Title); err != nil {
+		return nil, err
+	}
+
+	if err := validateRedirectStatus(newURL.RedirectStatus); err != nil {
+		return nil, err
+	}
+
+	if err := validateRedirectQuery(newURL.Query); err != nil {
+		return nil, err
+	}
+
+	targeting, err := s.resolveTargeting(newURL.Targeting)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := s.resolveVariants(newURL.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := resolveTags(newURL.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := resolveFolder(newURL.Folder)
 	if err != nil {
 		return nil, err
 	}
 
-	longURL := newURL.LongURL
+	shurl := entities.ShURL{
+		LongURL:        longURL,
+		CreatedBy:      newURL.CreatedBy,
+		CreatedAt:      s.now(),
+		Title:          newURL.Title,
+		RedirectStatus: newURL.RedirectStatus,
+		Query:          newURL.Query,
+		Targeting:      targeting,
+		Variants:       variants,
+		StickyVariants: newURL.StickyVariants && len(variants) > 0,
+		Tags:           tags,
+		Folder:         folder,
+		ExpiresAt:      expiresAt,
+		PasswordHash:   passwordHash,
+		ClicksLeft:     clicksLeft,
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+	}
 
-	for _, existedURL := range existedURLs {
-		// Проверяем не отменен ли контекст
-		if err := ctx.Err(); err != nil {
+	if newURL.Alias != "" {
+		// Пользователь явно запросил алиас - создаём отдельную ссылку даже если такой урл уже укорачивали
+		if err := validateAlias(newURL.Alias); err != nil {
 			return nil, err
 		}
 
-		//TODO: если разные пользователи укоротили один урл, дубль должен писаться? По идее да
-		if existedURL.LongURL == longURL {
-			return &existedURL, alreadyExistsError
+		shurl.Token = newURL.Alias
+		if err := s.repo.Create(ctx, &shurl); err != nil {
+			if repository.IsAlreadyExists(err) {
+				return nil, aliasTakenError
+			}
+			return nil, err
 		}
+
+		return &shurl, nil
 	}
 
-	//Добавление shurl в БД
-	generate, _ := nanoid.CustomASCII("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", 8)
-	token := generate() // Пример: "EwHXdJfB"
-	shurl := entities.ShURL{
-		Token:     token,
-		LongURL:   longURL,
-		CreatedBy: newURL.CreatedBy,
+	// Ссылка с особым поведением при переходе (см. isDeduplicable) всегда создаётся отдельно:
+	// существующая ссылка на тот же URL ведёт себя иначе
+	if isDeduplicable(&shurl) {
+		// Проверка наличие урла в БД
+		existedURLs, err := s.repo.GetByLongURL(ctx, longURL)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, existedURL := range existedURLs {
+			// Проверяем не отменен ли контекст
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			// По умолчанию дубли ищутся только среди ссылок создателя, чтобы не раскрывать чужие токены
+			if (s.globalDedup || existedURL.CreatedBy == newURL.CreatedBy) && isDeduplicable(&existedURL) {
+				return &existedURL, alreadyExistsError
+			}
+		}
 	}
 
-	err = s.repo.Create(ctx, &shurl)
-	if err != nil {
+	//Добавление shurl в БД
+	if err := s.createWithGeneratedToken(ctx, &shurl); err != nil {
 		return nil, err
 	}
 
 	return &shurl, nil
 }
 
-// GetAllShURLsByUserID - получить все ShURL конкретного пользователя (инкапсулирует все проверки бизнес-логику)
-func (s *ShURLService) getAllByUserID(ctx context.Context, userID string) ([]entities.ShURL, error) {
-	allShURLs, err := s.repo.GetAll(ctx)
+// isDeduplicable - может ли существующая ShURL быть возвращена как дубль новой ссылки на тот же URL.
+// Ссылки с паролем, ограничением переходов, окном активности, своим кодом перехода, правилами query-строки
+// правилами выбора адреса назначения или вариантами A/B-теста ведут себя иначе обычной, поэтому дублями не считаются.
+// Ссылки с метками или папкой тоже создаются отдельно, чтобы заданные при создании метки и папка не терялись
+func isDeduplicable(shURL *entities.ShURL) bool {
+	return shURL.PasswordHash == "" && shURL.ClicksLeft == nil && shURL.NotBefore == nil && shURL.NotAfter == nil &&
+		shURL.RedirectStatus == 0 && shURL.Query == (entities.RedirectQuery{}) &&
+		len(shURL.Targeting) == 0 && len(shURL.Variants) == 0 && len(shURL.Tags) == 0 && shURL.Folder == ""
+}
+
+// createWithGeneratedToken - сохранить ShURL под сгенерированным токеном.
+// Занятость токена проверяет сама вставка в репозиторий (без гонки между проверкой и записью),
+// при коллизии токен генерируется заново, но не более maxTokenAttempts раз
+func (s *ShURLService) createWithGeneratedToken(ctx context.Context, shurl *entities.ShURL) error {
+	for attempt := 0; ; attempt++ {
+		token, next, err := s.generateToken(shurl.LongURL, attempt)
+		if err != nil {
+			return err
+		}
+		attempt = next
+
+		shurl.Token = token
+		err = s.repo.Create(ctx, shurl)
+		if !repository.IsAlreadyExists(err) {
+			return err
+		}
+
+		s.recordTokenCollision(token, attempt)
+	}
+}
+
+// generateToken - сгенерировать токен, начиная с попытки attempt и пропуская зарезервированные маршруты.
+// Возвращает токен и номер попытки, на которой он получен
+func (s *ShURLService) generateToken(longURL string, attempt int) (string, int, error) {
+	for ; attempt < maxTokenAttempts; attempt++ {
+		token, err := s.tokens.Generate(longURL, attempt)
+		if err != nil {
+			return "", attempt, err
+		}
+
+		if !isReservedToken(token) {
+			return token, attempt, nil
+		}
+	}
+
+	tokenAttemptsExhausted.Add(1)
+	s.logger.Error("failed to generate a unique token", zap.Int("attempts", maxTokenAttempts))
+
+	return "", attempt, tokenExhaustedError
+}
+
+// recordTokenCollision - учесть коллизию сгенерированного токена в метриках и логе
+func (s *ShURLService) recordTokenCollision(token string, attempt int) {
+	tokenCollisions.Add(1)
+	s.logger.Warn("generated token collides with existing shurl",
+		zap.String("token", token),
+		zap.Int("attempt", attempt),
+	)
+}
+
+// update - изменить ShURL (инкапсулирует все проверки бизнес-логику)
+func (s *ShURLService) update(ctx context.Context, update dtos.UpdateShURL) (*entities.ShURL, error) {
+	// Удалённые и истёкшие ссылки не редактируются (404/410)
+	shURL, err := s.get(ctx, update.Token)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []entities.ShURL
-	for _, shURL := range allShURLs {
-		if shURL.CreatedBy == userID {
-			result = append(result, shURL)
+	if shURL.CreatedBy != update.UpdatedBy {
+		return nil, forbiddenError
+	}
+
+	if !update.KeepLongURL {
+		longURL, err := s.normalizeLongURL(update.LongURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.checkDestination(longURL); err != nil {
+			return nil, err
+		}
+
+		shURL.LongURL = longURL
+	}
+
+	if update.Tags != nil {
+		if shURL.Tags, err = resolveTags(*update.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Folder != nil {
+		if shURL.Folder, err = resolveFolder(*update.Folder); err != nil {
+			return nil, err
 		}
 	}
 
-	return result, nil
+	err = s.repo.Update(ctx, shURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return shURL, nil
+}
+
+// getAllByUserID - получить страницу ShURL конкретного пользователя (инкапсулирует все проверки бизнес-логику)
+func (s *ShURLService) getAllByUserID(ctx context.Context, userID string, filter dtos.ShURLFilter) (*userShURLsPage, error) {
+	filter, err := resolveFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	shURLs, next, err := s.repo.GetByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userShURLsPage{shURLs: shURLs, next: next}, nil
 }
 
 // Shutdown - инициирует graceful shutdown сервиса
@@ -279,9 +570,21 @@ func (s *ShURLService) Shutdown() {
 	//Помечаем сервис как завершающий работу
 	s.isShuttingDown.Store(true)
 
+	//Останавливаем фоновые горутины
+	close(s.stopBackground)
+	s.backgroundTasks.Wait()
+
+	//Дописываем накопленные события переходов
+	if s.clicks != nil {
+		s.clicks.shutdown()
+	}
+
+	//Удаляем ShURL, ожидающие пакетного удаления
+	s.deletions.shutdown()
+
 	//Ждем завершения всех задач
 	s.tasksInProcess.Wait()
 
-	//Закрываем очередь
-	close(s.taskQueue)
+	//Закрываем очереди и дожидаемся остановки воркеров
+	s.stopWorkers()
 }
@@ -3,13 +3,28 @@ package services_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/JustScorpio/urlshortener/internal/customerrors"
 	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+	"github.com/JustScorpio/urlshortener/internal/policy"
+	"github.com/JustScorpio/urlshortener/internal/repository"
 	"github.com/JustScorpio/urlshortener/internal/repository/inmemory"
+	"github.com/JustScorpio/urlshortener/internal/repository/jsonfile"
 	"github.com/JustScorpio/urlshortener/internal/services"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,36 +67,1188 @@ func TestShURLService_Create(t *testing.T) {
 	})
 }
 
+// TestShURLService_CreateDeduplication - проверка области поиска дублей длинного URL
+func TestShURLService_CreateDeduplication(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("duplicates are detected per user by default", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+
+		first, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		second, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user2"})
+		require.NoError(t, err)
+		assert.NotEqual(t, first.Token, second.Token)
+		assert.Equal(t, "user2", second.CreatedBy)
+
+		again, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user2"})
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+		assert.Equal(t, second.Token, again.Token)
+	})
+
+	t.Run("global deduplication returns another user's shurl", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithGlobalDeduplication(true))
+
+		first, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		second, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user2"})
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+		assert.Equal(t, first.Token, second.Token)
+	})
+}
+
+// TestShURLService_CreateWithAlias - проверка создания ShURL с пользовательским алиасом
+func TestShURLService_CreateWithAlias(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	ctx := context.Background()
+
+	t.Run("successful creation with alias", func(t *testing.T) {
+		shURL, err := service.Create(ctx, dtos.NewShURL{
+			LongURL:   "https://example.com/report",
+			CreatedBy: "user1",
+			Alias:     "q3-report",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "q3-report", shURL.Token)
+
+		got, err := service.Get(ctx, "q3-report")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/report", got.LongURL)
+	})
+
+	t.Run("taken alias returns conflict", func(t *testing.T) {
+		shURL, err := service.Create(ctx, dtos.NewShURL{
+			LongURL:   "https://example.com/other",
+			CreatedBy: "user2",
+			Alias:     "q3-report",
+		})
+		assert.Nil(t, shURL)
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+	})
+
+	t.Run("alias of deleted shurl returns conflict", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{
+			LongURL:   "https://example.com/deleted",
+			CreatedBy: "user1",
+			Alias:     "deleted-link",
+		})
+		require.NoError(t, err)
+		require.NoError(t, service.Delete(ctx, []string{"deleted-link"}, "user1"))
+
+		_, err = service.Create(ctx, dtos.NewShURL{
+			LongURL:   "https://example.com/deleted",
+			CreatedBy: "user1",
+			Alias:     "deleted-link",
+		})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+	})
+
+	invalidAliases := map[string]string{
+		"too short":         "ab",
+		"too long":          strings.Repeat("a", 65),
+		"invalid charset":   "q3/report",
+		"reserved route":    "ping",
+		"reserved any case": "API",
+	}
+	for name, alias := range invalidAliases {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.Create(ctx, dtos.NewShURL{
+				LongURL:   "https://example.com/invalid",
+				CreatedBy: "user1",
+				Alias:     alias,
+			})
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		})
+	}
+}
+
+// sequenceGenerator - генератор, выдающий токены из заранее заданной последовательности
+type sequenceGenerator struct {
+	tokens   []string
+	attempts []int
+}
+
+// Generate - вернуть следующий токен последовательности
+func (g *sequenceGenerator) Generate(_ string, attempt int) (string, error) {
+	g.attempts = append(g.attempts, attempt)
+	token := g.tokens[0]
+	if len(g.tokens) > 1 {
+		g.tokens = g.tokens[1:]
+	}
+
+	return token, nil
+}
+
+// TestShURLService_CreateTokenCollision - проверка повторной генерации токена при коллизии
+func TestShURLService_CreateTokenCollision(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	ctx := context.Background()
+
+	t.Run("taken and reserved tokens are skipped", func(t *testing.T) {
+		generator := &sequenceGenerator{tokens: []string{"taken", "taken", "ping", "free"}}
+		service := services.NewShURLService(mockRepo, services.WithTokenGenerator(generator))
+		defer service.Shutdown()
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/1", CreatedBy: "user1", Alias: "taken"})
+		require.NoError(t, err)
+
+		before := tokenMetric(t, "shurl_token_collisions_total")
+
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/2", CreatedBy: "user1"})
+		require.NoError(t, err)
+		assert.Equal(t, "free", shURL.Token)
+		assert.Equal(t, []int{0, 1, 2, 3}, generator.attempts)
+		assert.Equal(t, int64(2), tokenMetric(t, "shurl_token_collisions_total")-before)
+	})
+
+	t.Run("attempts are bounded", func(t *testing.T) {
+		generator := &sequenceGenerator{tokens: []string{"taken"}}
+		service := services.NewShURLService(mockRepo, services.WithTokenGenerator(generator))
+		defer service.Shutdown()
+
+		before := tokenMetric(t, "shurl_token_attempts_exhausted_total")
+
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/3", CreatedBy: "user1"})
+		assert.Nil(t, shURL)
+		assert.Error(t, err)
+		assert.NotEmpty(t, generator.attempts)
+		assert.Equal(t, int64(1), tokenMetric(t, "shurl_token_attempts_exhausted_total")-before)
+	})
+}
+
+// tokenMetric - значение метрики name, отдаваемое services.MetricsHandler
+func tokenMetric(t *testing.T, name string) int64 {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	services.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var metrics map[string]int64
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&metrics))
+	require.Contains(t, metrics, name)
+	return metrics[name]
+}
+
+// TestShURLService_CreateMany - проверка создания пачки ShURL с результатом по каждому элементу
+func TestShURLService_CreateMany(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("successful batch creation", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/1", CreatedBy: "user1"},
+			{LongURL: "https://example.com/2", CreatedBy: "user1", Alias: "second"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		for _, result := range results {
+			assert.Equal(t, dtos.BatchItemCreated, result.Status)
+			assert.NoError(t, result.Err)
+		}
+		assert.Equal(t, "second", results[1].ShURL.Token)
+
+		got, err := service.Get(ctx, results[0].ShURL.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/1", got.LongURL)
+	})
+
+	t.Run("invalid items do not block others", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/taken", CreatedBy: "user2", Alias: "taken"})
+		require.NoError(t, err)
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/1", CreatedBy: "user1"},
+			{LongURL: "https://example.com/2", CreatedBy: "user1", Alias: "taken"},
+			{LongURL: "", CreatedBy: "user1"},
+			{LongURL: "https://example.com/3", CreatedBy: "user1", Alias: "x"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.Equal(t, dtos.BatchItemCreated, results[0].Status)
+
+		codes := map[int]int{1: http.StatusConflict, 2: http.StatusBadRequest, 3: http.StatusBadRequest}
+		for i, code := range codes {
+			assert.Equal(t, dtos.BatchItemInvalid, results[i].Status)
+			assert.Nil(t, results[i].ShURL)
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(results[i].Err, &httpErr))
+			assert.Equal(t, code, httpErr.Code)
+		}
+
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{})
+		require.NoError(t, err)
+		assert.Len(t, shURLs, 1)
+	})
+
+	t.Run("duplicates return existing shurl", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		existing, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/1", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/2", CreatedBy: "user1"},
+			{LongURL: "https://example.com/1", CreatedBy: "user1"},
+			{LongURL: "https://example.com/2", CreatedBy: "user1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, dtos.BatchItemCreated, results[0].Status)
+		assert.Equal(t, dtos.BatchItemExisting, results[1].Status)
+		assert.Equal(t, existing.Token, results[1].ShURL.Token)
+		assert.Equal(t, dtos.BatchItemExisting, results[2].Status)
+		assert.Equal(t, results[0].ShURL.Token, results[2].ShURL.Token)
+	})
+
+	t.Run("alias repeated in batch is created once", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/1", CreatedBy: "user1", Alias: "same"},
+			{LongURL: "https://example.com/2", CreatedBy: "user1", Alias: "same"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, dtos.BatchItemCreated, results[0].Status)
+		assert.Equal(t, dtos.BatchItemInvalid, results[1].Status)
+	})
+
+	t.Run("colliding generated token is regenerated", func(t *testing.T) {
+		generator := &sequenceGenerator{tokens: []string{"taken", "first", "second"}}
+		service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithTokenGenerator(generator))
+		defer service.Shutdown()
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/0", CreatedBy: "user2", Alias: "taken"})
+		require.NoError(t, err)
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/1", CreatedBy: "user1"},
+			{LongURL: "https://example.com/2", CreatedBy: "user1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "second", results[0].ShURL.Token)
+		assert.Equal(t, "first", results[1].ShURL.Token)
+	})
+}
+
+// TestShURLService_URLNormalization - проверка проверки и нормализации длинного URL при создании
+func TestShURLService_URLNormalization(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("canonical form", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithURLNormalization(services.URLNormalization{
+			StripDefaultPort: true,
+			StripFragment:    true,
+		}))
+		defer service.Shutdown()
+
+		tests := map[string]string{
+			"  https://Example.COM/Path?q=1  ":  "https://example.com/Path?q=1",
+			"HTTP://example.com:80/a":           "http://example.com/a",
+			"https://example.com:443/a#section": "https://example.com/a",
+			"https://example.com:8443/a":        "https://example.com:8443/a",
+			"https://пример.рф/путь":            "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C",
+			"http://[2001:DB8::1]:80/":          "http://[2001:db8::1]/",
+			"https://example_text.com":          "https://example_text.com",
+		}
+		i := 0
+		for raw, expected := range tests {
+			i++
+			shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: raw, CreatedBy: fmt.Sprint("user", i)})
+			require.NoError(t, err, raw)
+			assert.Equal(t, expected, shURL.LongURL, raw)
+		}
+	})
+
+	t.Run("fragments and ports are kept by default", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://EXAMPLE.com:443/a#top", CreatedBy: "user1"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com:443/a#top", shURL.LongURL)
+	})
+
+	t.Run("rejected urls", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithURLNormalization(services.URLNormalization{
+			MaxLength: 64,
+		}))
+		defer service.Shutdown()
+
+		tests := map[string]string{
+			"":                        services.URLReasonEmpty,
+			"   ":                     services.URLReasonEmpty,
+			"javascript:alert(1)":     services.URLReasonScheme,
+			"ftp://example.com/file":  services.URLReasonScheme,
+			"example.com":             services.URLReasonScheme,
+			"https:///path":           services.URLReasonMissingHost,
+			"https://exa mple.com":    services.URLReasonMalformed,
+			"https://example.com/a b": services.URLReasonMalformed,
+			"http://%zz":              services.URLReasonMalformed,
+			"https://exa$mple.com":    services.URLReasonInvalidHost,
+			"https://example.com/" + strings.Repeat("a", 64): services.URLReasonTooLong,
+		}
+		for raw, reason := range tests {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: raw, CreatedBy: "user1"})
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), raw)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code, raw)
+			assert.Equal(t, reason, httpErr.Reason, raw)
+		}
+	})
+
+	t.Run("duplicates are detected on canonical form", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/a", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, dtos.NewShURL{LongURL: "https://EXAMPLE.com/a", CreatedBy: "user1"})
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+	})
+}
+
 // TestShURLService_Get - проверка получения ShURL
 func TestShURLService_Get(t *testing.T) {
 	mockRepo := inmemory.NewInMemoryRepository()
 	service := services.NewShURLService(mockRepo)
 	ctx := context.Background()
 
-	// Setup test data
-	newURL := dtos.NewShURL{
-		LongURL:   "https://example.com",
+	// Setup test data
+	newURL := dtos.NewShURL{
+		LongURL:   "https://example.com",
+		CreatedBy: "user1",
+	}
+	created, err := service.Create(ctx, newURL)
+	require.NoError(t, err)
+
+	t.Run("get existing URL", func(t *testing.T) {
+		shURL, err := service.Get(ctx, created.Token)
+		require.NoError(t, err)
+		assert.Equal(t, created.Token, shURL.Token)
+		assert.Equal(t, created.LongURL, shURL.LongURL)
+	})
+
+	t.Run("get non-existing URL", func(t *testing.T) {
+		shURL, err := service.Get(ctx, "nonexistent")
+		assert.Nil(t, shURL)
+
+		// NotFound
+		var httpErr *customerrors.HTTPError
+		assert.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+	})
+}
+
+// TestShURLService_DestinationPolicy - проверка политики адресов назначения при создании, изменении и переходе
+func TestShURLService_DestinationPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"id": "phishing", "action": "deny", "host": "*.evil.com"}]}`), 0644))
+	require.NoError(t, os.Chtimes(path, start, start))
+
+	engine, err := policy.NewEngine(path)
+	require.NoError(t, err)
+
+	service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithDestinationPolicy(engine, 0))
+	defer service.Shutdown()
+
+	assertBlocked := func(t *testing.T, err error, ruleID string) {
+		t.Helper()
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr), "expected HTTPError, got %v", err)
+		assert.Equal(t, http.StatusForbidden, httpErr.Code)
+		assert.Equal(t, services.URLReasonBlocked, httpErr.Reason)
+		assert.Equal(t, ruleID, httpErr.Details["rule_id"])
+	}
+
+	t.Run("create", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://login.EVIL.com/", CreatedBy: "user1"})
+		assertBlocked(t, err, "phishing")
+
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{
+			{LongURL: "https://example.com/ok", CreatedBy: "user1"},
+			{LongURL: "https://www.evil.com/", CreatedBy: "user1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, dtos.BatchItemCreated, results[0].Status)
+		assert.Equal(t, dtos.BatchItemInvalid, results[1].Status)
+		assertBlocked(t, results[1].Err, "phishing")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/update", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		_, err = service.Update(ctx, dtos.UpdateShURL{Token: shURL.Token, LongURL: "https://www.evil.com/", UpdatedBy: "user1"})
+		assertBlocked(t, err, "phishing")
+	})
+
+	t.Run("redirect uses reloaded rules", func(t *testing.T) {
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/later-blocked", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		_, err = service.Resolve(ctx, shURL.Token)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"id": "example-ban", "action": "deny", "host": "example.com"}]}`), 0644))
+		require.NoError(t, os.Chtimes(path, start.Add(time.Minute), start.Add(time.Minute)))
+		_, err = engine.Reload()
+		require.NoError(t, err)
+
+		_, err = service.Resolve(ctx, shURL.Token)
+		assertBlocked(t, err, "example-ban")
+
+		// Get (владелец, статистика) политикой не ограничивается
+		_, err = service.Get(ctx, shURL.Token)
+		assert.NoError(t, err)
+	})
+}
+
+// TestShURLService_Password - проверка защищённых паролем ссылок и ограничения неудачных попыток
+func TestShURLService_Password(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service := services.NewShURLService(inmemory.NewInMemoryRepository(),
+		services.WithClock(clock.Now),
+		services.WithExpirySweepInterval(0),
+		services.WithPasswordAttemptLimit(2, time.Minute),
+	)
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/draft", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	protected, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/draft", CreatedBy: "user1", Password: "s3cret"})
+	require.NoError(t, err, "protected link must not be deduplicated with the plain one")
+	assert.NotEqual(t, plain.Token, protected.Token)
+	assert.NotEmpty(t, protected.PasswordHash)
+	assert.NotContains(t, protected.PasswordHash, "s3cret")
+
+	t.Run("plain link is still the duplicate", func(t *testing.T) {
+		existing, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/draft", CreatedBy: "user1"})
+		assert.Error(t, err)
+		assert.Equal(t, plain.Token, existing.Token)
+	})
+
+	t.Run("too long password", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/long", CreatedBy: "user1", Password: strings.Repeat("p", 73)})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Equal(t, services.URLReasonPasswordTooLong, httpErr.Reason)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		shURL, err := service.Unlock(ctx, protected.Token, "s3cret")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/draft", shURL.LongURL)
+
+		// Ссылка без пароля открывается с любым паролем
+		_, err = service.Unlock(ctx, plain.Token, "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed attempts are limited per token", func(t *testing.T) {
+		other, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/other", CreatedBy: "user1", Password: "other"})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := service.Unlock(ctx, protected.Token, "wrong")
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
+		}
+
+		// Даже верный пароль отклоняется до конца окна
+		_, err = service.Unlock(ctx, protected.Token, "s3cret")
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+		assert.Equal(t, "60", httpErr.Details["retry_after"])
+
+		// Другие ссылки не затронуты
+		_, err = service.Unlock(ctx, other.Token, "other")
+		assert.NoError(t, err)
+
+		clock.Advance(time.Minute)
+		_, err = service.Unlock(ctx, protected.Token, "s3cret")
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent attempts do not exceed the limit", func(t *testing.T) {
+		target, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/target", CreatedBy: "user1", Password: "s3cret"})
+		require.NoError(t, err)
+
+		// Попытки засчитываются до проверки пароля, поэтому из параллельных запросов проверяются не больше двух
+		const guesses = 20
+		codes := make(chan int, guesses)
+		var wg sync.WaitGroup
+		for i := 0; i < guesses; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				_, err := service.Unlock(ctx, target.Token, fmt.Sprintf("guess-%d", i))
+				var httpErr *customerrors.HTTPError
+				if errors.As(err, &httpErr) {
+					codes <- httpErr.Code
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(codes)
+
+		counts := make(map[int]int)
+		for code := range codes {
+			counts[code]++
+		}
+		assert.Equal(t, map[int]int{http.StatusUnauthorized: 2, http.StatusTooManyRequests: guesses - 2}, counts)
+	})
+}
+
+// TestShURLService_MaxClicks - проверка ограничения количества переходов
+func TestShURLService_MaxClicks(t *testing.T) {
+	ctx := context.Background()
+
+	jsonRepo, err := jsonfile.NewJSONFileShURLRepository(filepath.Join(t.TempDir(), "shurls.json"))
+	require.NoError(t, err)
+
+	repos := map[string]repository.IRepository[entities.ShURL]{
+		"inmemory": inmemory.NewInMemoryRepository(),
+		"jsonfile": jsonRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			service := services.NewShURLService(repo, services.WithWorkers(4))
+			defer service.Shutdown()
+
+			plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/once", CreatedBy: "user1"})
+			require.NoError(t, err)
+
+			limited, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/once", CreatedBy: "user1", MaxClicks: 3})
+			require.NoError(t, err, "limited link must not be deduplicated with the plain one")
+			assert.NotEqual(t, plain.Token, limited.Token)
+
+			// Параллельные переходы не могут списать больше переходов, чем осталось
+			var consumed atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					shURL, err := service.Resolve(ctx, limited.Token)
+					if err != nil {
+						return
+					}
+
+					if service.ConsumeClick(ctx, shURL) == nil {
+						consumed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(3), consumed.Load())
+
+			_, err = service.Resolve(ctx, limited.Token)
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusGone, httpErr.Code)
+
+			// Переходы по ссылке без ограничения не списываются
+			for i := 0; i < 5; i++ {
+				assert.NoError(t, service.ConsumeClick(ctx, plain))
+			}
+		})
+	}
+
+	t.Run("negative max_clicks", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1", MaxClicks: -1})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+	})
+}
+
+// TestShURLService_Schedule - проверка окна активности ShURL
+func TestShURLService_Schedule(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithClock(clock.Now), services.WithExpirySweepInterval(0))
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	notBefore := clock.Now().Add(time.Hour)
+	notAfter := clock.Now().Add(2 * time.Hour)
+	scheduled, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/launch",
+		CreatedBy: "user1",
+		NotBefore: &notBefore,
+		NotAfter:  &notAfter,
+	})
+	require.NoError(t, err)
+
+	t.Run("before activation", func(t *testing.T) {
+		_, err := service.Resolve(ctx, scheduled.Token)
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+		assert.Equal(t, services.LinkReasonNotYetActive, httpErr.Reason)
+		assert.Equal(t, "2025-01-01T13:00:00Z", httpErr.Details["not_before"])
+
+		// Владелец видит ссылку и может её изменять
+		shURL, err := service.Get(ctx, scheduled.Token)
+		require.NoError(t, err)
+		assert.Equal(t, notBefore, *shURL.NotBefore)
+	})
+
+	t.Run("inside window", func(t *testing.T) {
+		clock.Advance(time.Hour)
+
+		_, err := service.Resolve(ctx, scheduled.Token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("after window", func(t *testing.T) {
+		clock.Advance(time.Hour)
+
+		_, err := service.Resolve(ctx, scheduled.Token)
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusGone, httpErr.Code)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		past := clock.Now().Add(-time.Minute)
+		later := clock.Now().Add(time.Hour)
+		earlier := clock.Now().Add(30 * time.Minute)
+
+		invalid := map[string]dtos.NewShURL{
+			"not_after in the past":       {LongURL: "https://example.com/a", CreatedBy: "user1", NotAfter: &past},
+			"not_after before not_before": {LongURL: "https://example.com/b", CreatedBy: "user1", NotBefore: &later, NotAfter: &earlier},
+		}
+		for name, newURL := range invalid {
+			_, err := service.Create(ctx, newURL)
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), name)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code, name)
+		}
+	})
+}
+
+// TestShURLService_TitleAndCreatedAt - проверка заголовка и момента создания ссылки (для предпросмотра)
+func TestShURLService_TitleAndCreatedAt(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithClock(clock.Now), services.WithExpirySweepInterval(0))
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	t.Run("create stores title and creation time", func(t *testing.T) {
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/titled", CreatedBy: "user1", Title: "Release notes"})
+		require.NoError(t, err)
+
+		stored, err := service.Get(ctx, shURL.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "Release notes", stored.Title)
+		assert.True(t, clock.Now().Equal(stored.CreatedAt))
+	})
+
+	t.Run("batch stores title and creation time", func(t *testing.T) {
+		results, err := service.CreateMany(ctx, []dtos.NewShURL{{LongURL: "https://example.com/batch-titled", CreatedBy: "user1", Title: "Batch"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.NotNil(t, results[0].ShURL)
+
+		assert.Equal(t, "Batch", results[0].ShURL.Title)
+		assert.True(t, clock.Now().Equal(results[0].ShURL.CreatedAt))
+	})
+
+	t.Run("too long title is rejected", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/long-title", CreatedBy: "user1", Title: strings.Repeat("я", 257)})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Equal(t, services.URLReasonTitleTooLong, httpErr.Reason)
+	})
+}
+
+// TestShURLService_RedirectStatus - проверка кода ответа при переходе, заданного для ссылки
+func TestShURLService_RedirectStatus(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/seo", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	t.Run("permanent link is stored separately from plain duplicate", func(t *testing.T) {
+		permanent, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/seo", CreatedBy: "user1", RedirectStatus: http.StatusMovedPermanently})
+		require.NoError(t, err)
+		assert.NotEqual(t, plain.Token, permanent.Token)
+
+		stored, err := service.Get(ctx, permanent.Token)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, stored.RedirectStatus)
+	})
+
+	t.Run("invalid status is rejected", func(t *testing.T) {
+		for _, code := range []int{200, 303, 404} {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/bad-status", CreatedBy: "user1", RedirectStatus: code})
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), code)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Equal(t, services.URLReasonInvalidRedirectStatus, httpErr.Reason)
+		}
+	})
+}
+
+// TestDestinationURL - проверка UTM-меток и передачи параметров запроса в адрес назначения
+func TestDestinationURL(t *testing.T) {
+	utm := entities.RedirectQuery{UTMSource: "shortener", UTMMedium: "link", UTMCampaign: "{token}-{referrer}"}
+
+	tests := []struct {
+		name     string
+		longURL  string
+		query    entities.RedirectQuery
+		incoming url.Values
+		expected string
+	}{
+		{
+			name:     "no rules keeps destination and drops incoming query",
+			longURL:  "https://example.com/page?a=1",
+			incoming: url.Values{"b": {"2"}},
+			expected: "https://example.com/page?a=1",
+		},
+		{
+			name:     "utm templates",
+			longURL:  "https://example.com/page#top",
+			query:    utm,
+			expected: "https://example.com/page?utm_campaign=tok-news.example.org&utm_medium=link&utm_source=shortener#top",
+		},
+		{
+			name:     "passthrough keeps original parameters",
+			longURL:  "https://example.com/page?a=1&q=%D0%B0+b",
+			query:    entities.RedirectQuery{Passthrough: true},
+			incoming: url.Values{"a": {"9"}, "b": {"2", "3"}},
+			expected: "https://example.com/page?a=1&q=%D0%B0+b&b=2&b=3",
+		},
+		{
+			name:     "passthrough overrides original parameters",
+			longURL:  "https://example.com/page?a=1&c=3",
+			query:    entities.RedirectQuery{Passthrough: true, Conflict: services.QueryConflictOverride},
+			incoming: url.Values{"a": {"9"}},
+			expected: "https://example.com/page?c=3&a=9",
+		},
+		{
+			name:     "utm wins over incoming with keep",
+			longURL:  "https://example.com/page?utm_medium=email",
+			query:    entities.RedirectQuery{Passthrough: true, UTMSource: "shortener", UTMMedium: "link"},
+			incoming: url.Values{"utm_source": {"spoofed"}},
+			expected: "https://example.com/page?utm_medium=email&utm_source=shortener",
+		},
+		{
+			name:     "incoming wins over utm with override",
+			longURL:  "https://example.com/page?utm_medium=email",
+			query:    entities.RedirectQuery{Passthrough: true, UTMSource: "shortener", UTMMedium: "link", Conflict: services.QueryConflictOverride},
+			incoming: url.Values{"utm_source": {"partner"}},
+			expected: "https://example.com/page?utm_medium=link&utm_source=partner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shURL := &entities.ShURL{Token: "tok", LongURL: tt.longURL, Query: tt.query}
+
+			assert.Equal(t, tt.expected, services.DestinationURL(shURL, tt.incoming, "https://news.example.org/today"))
+		})
+	}
+}
+
+// TestShURLService_RedirectQuery - проверка сохранения правил формирования query-строки
+func TestShURLService_RedirectQuery(t *testing.T) {
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	t.Run("rules are stored and disable deduplication", func(t *testing.T) {
+		plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/campaign", CreatedBy: "user1"})
+		require.NoError(t, err)
+
+		query := entities.RedirectQuery{Passthrough: true, UTMSource: "newsletter", Conflict: services.QueryConflictOverride}
+		tagged, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/campaign", CreatedBy: "user1", Query: query})
+		require.NoError(t, err)
+		assert.NotEqual(t, plain.Token, tagged.Token)
+
+		stored, err := service.Get(ctx, tagged.Token)
+		require.NoError(t, err)
+		assert.Equal(t, query, stored.Query)
+	})
+
+	t.Run("unknown conflict policy is rejected", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/campaign", CreatedBy: "user1", Query: entities.RedirectQuery{Conflict: "merge"}})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Equal(t, services.URLReasonInvalidQueryConflict, httpErr.Reason)
+	})
+}
+
+// TestShURLService_Targeting - проверка выбора адреса назначения по правилам ссылки
+func TestShURLService_Targeting(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithClock(clock.Now), services.WithExpirySweepInterval(0))
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	shURL, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/app",
+		CreatedBy: "user1",
+		Targeting: []entities.TargetingRule{
+			{Header: "x-beta", HeaderValue: "yes", URL: "https://Beta.Example.com"},
+			{Device: "iOS", URL: "https://apps.apple.com/app/id1"},
+			{Device: "android", URL: "https://play.google.com/store/apps/details?id=app"},
+			{Language: "ru", URL: "https://example.com/ru/app"},
+			{From: "22:00", To: "06:00", TimeZone: "Europe/Moscow", URL: "https://example.com/night"},
+		},
+	})
+	require.NoError(t, err)
+
+	stored, err := service.Get(ctx, shURL.Token)
+	require.NoError(t, err)
+	require.Len(t, stored.Targeting, 5)
+	assert.Equal(t, "X-Beta", stored.Targeting[0].Header)
+	assert.Equal(t, services.DeviceIOS, stored.Targeting[1].Device)
+	assert.Equal(t, "https://beta.example.com", stored.Targeting[0].URL)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected string
+	}{
+		{"ios", http.Header{"User-Agent": {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"}}, "https://apps.apple.com/app/id1"},
+		{"android", http.Header{"User-Agent": {"Mozilla/5.0 (Linux; Android 14; Pixel 8)"}}, "https://play.google.com/store/apps/details?id=app"},
+		{"language with region", http.Header{"Accept-Language": {"de;q=0.9, ru-RU"}}, "https://example.com/ru/app"},
+		{"rejected language", http.Header{"Accept-Language": {"de, ru;q=0"}}, "https://example.com/app"},
+		{"header wins by order", http.Header{"X-Beta": {"YES"}, "User-Agent": {"Mozilla/5.0 (iPhone)"}}, "https://beta.example.com"},
+		{"fallback", http.Header{"User-Agent": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64)"}}, "https://example.com/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination, err := service.Destination(stored, dtos.RedirectRequest{Header: tt.header})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, destination.URL)
+		})
+	}
+
+	t.Run("time window crosses midnight in rule time zone", func(t *testing.T) {
+		// 20:00 UTC - 23:00 в Москве
+		clock.Advance(8 * time.Hour)
+
+		destination, err := service.Destination(stored, dtos.RedirectRequest{Header: http.Header{}})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/night", destination.URL)
+	})
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		for _, rule := range []entities.TargetingRule{
+			{Device: "blackberry", URL: "https://example.com/bb"},
+			{URL: "https://example.com/always"},
+			{From: "25:00", To: "06:00", URL: "https://example.com/night"},
+			{From: "22:00", URL: "https://example.com/night"},
+			{HeaderValue: "yes", URL: "https://example.com/beta"},
+		} {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/app", CreatedBy: "user1", Targeting: []entities.TargetingRule{rule}})
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), rule)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Equal(t, services.URLReasonInvalidTargeting, httpErr.Reason)
+			assert.Equal(t, "0", httpErr.Details["rule"])
+		}
+	})
+
+	t.Run("too many rules are rejected without rule index", func(t *testing.T) {
+		rules := make([]entities.TargetingRule, 21)
+		for i := range rules {
+			rules[i] = entities.TargetingRule{Device: "ios", URL: fmt.Sprintf("https://example.com/%d", i)}
+		}
+
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/app", CreatedBy: "user1", Targeting: rules})
+
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Equal(t, services.URLReasonInvalidTargeting, httpErr.Reason)
+		assert.NotContains(t, httpErr.Details, "rule")
+	})
+}
+
+// TestShURLService_Variants - проверка выбора варианта A/B-теста
+func TestShURLService_Variants(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo, services.WithClickStore(mockRepo))
+	ctx := context.Background()
+
+	shURL, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/landing",
+		CreatedBy: "user1",
+		Variants: []entities.Variant{
+			{URL: "https://example.com/landing-a", Weight: 3},
+			{ID: "green", URL: "https://example.com/landing-green", Weight: 1},
+		},
+		StickyVariants: true,
+	})
+	require.NoError(t, err)
+
+	stored, err := service.Get(ctx, shURL.Token)
+	require.NoError(t, err)
+	require.Len(t, stored.Variants, 2)
+	assert.Equal(t, "a", stored.Variants[0].ID)
+	assert.True(t, stored.StickyVariants)
+
+	t.Run("variants are chosen by weight", func(t *testing.T) {
+		served := make(map[string]int)
+		for i := 0; i < 4000; i++ {
+			target, err := service.Destination(stored, dtos.RedirectRequest{Header: http.Header{}})
+			require.NoError(t, err)
+			served[target.Variant]++
+
+			if target.Variant == "green" {
+				assert.Equal(t, "https://example.com/landing-green", target.URL)
+			}
+		}
+
+		assert.Len(t, served, 2)
+		assert.InDelta(t, 3000, served["a"], 200)
+	})
+
+	t.Run("sticky variant is kept", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			target, err := service.Destination(stored, dtos.RedirectRequest{Header: http.Header{}, Variant: "green"})
+			require.NoError(t, err)
+			assert.Equal(t, "green", target.Variant)
+		}
+	})
+
+	t.Run("paused sticky variant is reassigned", func(t *testing.T) {
+		paused := *stored
+		paused.Variants = []entities.Variant{{ID: "a", URL: "https://example.com/landing-a", Weight: 1}, {ID: "green", URL: "https://example.com/landing-green"}}
+
+		target, err := service.Destination(&paused, dtos.RedirectRequest{Header: http.Header{}, Variant: "green"})
+		require.NoError(t, err)
+		assert.Equal(t, "a", target.Variant)
+	})
+
+	t.Run("clicks are counted per variant", func(t *testing.T) {
+		service.RecordClick(entities.Click{Token: shURL.Token, Variant: "a"})
+		service.RecordClick(entities.Click{Token: shURL.Token, Variant: "green"})
+		service.RecordClick(entities.Click{Token: shURL.Token, Variant: "a"})
+		service.Shutdown()
+
+		stats, err := mockRepo.GetClickStats(ctx, shURL.Token)
+		require.NoError(t, err)
+		assert.Equal(t, []dtos.VariantClicks{{Variant: "a", Clicks: 2}, {Variant: "green", Clicks: 1}}, stats.Variants)
+	})
+
+	t.Run("invalid variants are rejected", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository())
+		defer service.Shutdown()
+
+		for _, variants := range [][]entities.Variant{
+			{{URL: "https://example.com/only", Weight: 1}},
+			{{ID: "x", URL: "https://example.com/1", Weight: 1}, {ID: "x", URL: "https://example.com/2", Weight: 1}},
+			{{URL: "https://example.com/1"}, {URL: "https://example.com/2"}},
+			{{URL: "https://example.com/1", Weight: 1}, {URL: "https://example.com/2", Weight: -1}},
+			{{URL: "https://example.com/1", Weight: 1}, {URL: "https://example.com/2", Weight: math.MaxInt}},
+			{{URL: "https://example.com/1", Weight: 6000}, {URL: "https://example.com/2", Weight: 6000}},
+			{{ID: "bad id", URL: "https://example.com/1", Weight: 1}, {URL: "https://example.com/2", Weight: 1}},
+		} {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/landing", CreatedBy: "user1", Variants: variants})
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), variants)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Equal(t, services.URLReasonInvalidVariants, httpErr.Reason)
+		}
+	})
+}
+
+// TestShURLService_TagsAndFolder - проверка меток и папок ShURL
+func TestShURLService_TagsAndFolder(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	work, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/report",
 		CreatedBy: "user1",
-	}
-	created, err := service.Create(ctx, newURL)
+		Tags:      []string{" Work ", "q3", "work"},
+		Folder:    " Reports ",
+	})
 	require.NoError(t, err)
+	assert.Equal(t, []string{"q3", "work"}, work.Tags)
+	assert.Equal(t, "Reports", work.Folder)
 
-	t.Run("get existing URL", func(t *testing.T) {
-		shURL, err := service.Get(ctx, created.Token)
+	// Ссылка с метками не считается дублем обычной ссылки на тот же URL
+	plain, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/report", CreatedBy: "user1"})
+	require.NoError(t, err)
+	assert.NotEqual(t, work.Token, plain.Token)
+
+	_, err = service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/blog", CreatedBy: "user1", Tags: []string{"blog", "work"}})
+	require.NoError(t, err)
+
+	t.Run("listing is filtered by tags and folder", func(t *testing.T) {
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{Tags: []string{"WORK"}})
 		require.NoError(t, err)
-		assert.Equal(t, created.Token, shURL.Token)
-		assert.Equal(t, created.LongURL, shURL.LongURL)
+		assert.Len(t, shURLs, 2)
+
+		shURLs, _, err = service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{Tags: []string{"work", "q3"}})
+		require.NoError(t, err)
+		require.Len(t, shURLs, 1)
+		assert.Equal(t, work.Token, shURLs[0].Token)
+
+		shURLs, _, err = service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{Folder: "Reports"})
+		require.NoError(t, err)
+		require.Len(t, shURLs, 1)
+		assert.Equal(t, work.Token, shURLs[0].Token)
+
+		shURLs, _, err = service.GetAllShURLsByUserID(ctx, "user2", dtos.ShURLFilter{Tags: []string{"work"}})
+		require.NoError(t, err)
+		assert.Empty(t, shURLs)
 	})
 
-	t.Run("get non-existing URL", func(t *testing.T) {
-		shURL, err := service.Get(ctx, "nonexistent")
-		assert.Nil(t, shURL)
+	t.Run("tags and folder are updated without changing long URL", func(t *testing.T) {
+		tags := []string{"archive"}
+		folder := ""
+		updated, err := service.Update(ctx, dtos.UpdateShURL{Token: work.Token, UpdatedBy: "user1", KeepLongURL: true, Tags: &tags, Folder: &folder})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/report", updated.LongURL)
 
-		// NotFound
+		stored, err := service.Get(ctx, work.Token)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"archive"}, stored.Tags)
+		assert.Empty(t, stored.Folder)
+	})
+
+	t.Run("invalid tags and folder are rejected", func(t *testing.T) {
+		for _, newURL := range []dtos.NewShURL{
+			{Tags: []string{"two words"}},
+			{Tags: []string{""}},
+			{Tags: []string{strings.Repeat("a", 33)}},
+			{Folder: strings.Repeat("f", 65)},
+			{Folder: "bad\nfolder"},
+		} {
+			newURL.LongURL, newURL.CreatedBy = "https://example.com/invalid", "user1"
+			_, err := service.Create(ctx, newURL)
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), newURL)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Contains(t, []string{services.URLReasonInvalidTags, services.URLReasonInvalidFolder}, httpErr.Reason)
+		}
+	})
+}
+
+// TestShURLService_Update - проверка изменения адреса ShURL
+func TestShURLService_Update(t *testing.T) {
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo)
+	ctx := context.Background()
+
+	// Setup test data
+	created, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/old", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	deleted, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/deleted", CreatedBy: "user1"})
+	require.NoError(t, err)
+	require.NoError(t, service.Delete(ctx, []string{deleted.Token}, "user1"))
+	require.Eventually(t, func() bool {
+		_, err := service.Get(ctx, deleted.Token)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("owner updates long URL", func(t *testing.T) {
+		shURL, err := service.Update(ctx, dtos.UpdateShURL{Token: created.Token, UpdatedBy: "user1", LongURL: "https://example.com/new"})
+		require.NoError(t, err)
+		assert.Equal(t, created.Token, shURL.Token)
+
+		got, err := service.Get(ctx, created.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", got.LongURL)
+	})
+
+	t.Run("duplicate detection follows updated long URL", func(t *testing.T) {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/new", CreatedBy: "user1"})
 		var httpErr *customerrors.HTTPError
-		assert.True(t, errors.As(err, &httpErr))
-		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+
+		_, err = service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/old", CreatedBy: "user1"})
+		require.NoError(t, err)
 	})
+
+	errorCases := []struct {
+		name   string
+		update dtos.UpdateShURL
+		code   int
+	}{
+		{"another user is forbidden", dtos.UpdateShURL{Token: created.Token, UpdatedBy: "user2", LongURL: "https://evil.com"}, http.StatusForbidden},
+		{"non-existing token", dtos.UpdateShURL{Token: "nonexistent", UpdatedBy: "user1", LongURL: "https://example.com"}, http.StatusNotFound},
+		{"deleted shurl", dtos.UpdateShURL{Token: deleted.Token, UpdatedBy: "user1", LongURL: "https://example.com"}, http.StatusGone},
+		{"empty long URL", dtos.UpdateShURL{Token: created.Token, UpdatedBy: "user1", LongURL: " "}, http.StatusBadRequest},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.Update(ctx, tc.update)
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tc.code, httpErr.Code)
+		})
+	}
 }
 
 // TestShURLService_GetAllShURLsByUserID - проверка получения ShURL конкретного пользователя
@@ -103,7 +1270,7 @@ func TestShURLService_GetAllShURLsByUserID(t *testing.T) {
 	}
 
 	t.Run("get URLs by user1", func(t *testing.T) {
-		shURLs, err := service.GetAllShURLsByUserID(ctx, "user1")
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{})
 		require.NoError(t, err)
 		assert.Len(t, shURLs, 2)
 		for _, shURL := range shURLs {
@@ -112,23 +1279,285 @@ func TestShURLService_GetAllShURLsByUserID(t *testing.T) {
 	})
 
 	t.Run("get URLs by user2", func(t *testing.T) {
-		shURLs, err := service.GetAllShURLsByUserID(ctx, "user2")
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user2", dtos.ShURLFilter{})
 		require.NoError(t, err)
 		assert.Len(t, shURLs, 1)
 		assert.Equal(t, "user2", shURLs[0].CreatedBy)
 	})
 
 	t.Run("get URLs by non-existing user", func(t *testing.T) {
-		shURLs, err := service.GetAllShURLsByUserID(ctx, "nonexistent")
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "nonexistent", dtos.ShURLFilter{})
 		require.NoError(t, err)
 		assert.Empty(t, shURLs)
 	})
 }
 
+// TestShURLService_ListPagination - проверка сортировки, поиска и постраничного получения ShURL пользователя
+func TestShURLService_ListPagination(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo, services.WithClock(clock.Now), services.WithExpirySweepInterval(0))
+	defer service.Shutdown()
+	ctx := context.Background()
+
+	for _, alias := range []string{"delta", "alpha", "charlie", "bravo"} {
+		_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/" + alias, CreatedBy: "user1", Alias: alias, Title: "Page " + alias})
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+	}
+
+	require.NoError(t, mockRepo.AddClicks(ctx, []entities.Click{{Token: "bravo"}, {Token: "bravo"}, {Token: "charlie"}}))
+
+	// list - получить все ссылки постранично и вернуть их токены в порядке выдачи
+	list := func(filter dtos.ShURLFilter) []string {
+		var tokens []string
+		for {
+			shURLs, next, err := service.GetAllShURLsByUserID(ctx, "user1", filter)
+			require.NoError(t, err)
+			for _, shURL := range shURLs {
+				tokens = append(tokens, shURL.Token)
+			}
+
+			if next == nil {
+				return tokens
+			}
+			filter.After = next
+		}
+	}
+
+	t.Run("pages follow sort order", func(t *testing.T) {
+		assert.Equal(t, []string{"delta", "alpha", "charlie", "bravo"}, list(dtos.ShURLFilter{Limit: 3}))
+		assert.Equal(t, []string{"bravo", "charlie", "alpha", "delta"}, list(dtos.ShURLFilter{Sort: dtos.SortCreated, Desc: true, Limit: 1}))
+		assert.Equal(t, []string{"alpha", "bravo", "charlie", "delta"}, list(dtos.ShURLFilter{Sort: dtos.SortAlias, Limit: 2}))
+		assert.Equal(t, []string{"bravo", "charlie", "delta", "alpha"}, list(dtos.ShURLFilter{Sort: dtos.SortClicks, Desc: true, Limit: 2}))
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		shURLs, next, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{Limit: 4})
+		require.NoError(t, err)
+		assert.Len(t, shURLs, 4)
+		assert.Nil(t, next)
+	})
+
+	t.Run("search matches long URL and title", func(t *testing.T) {
+		assert.Equal(t, []string{"charlie"}, list(dtos.ShURLFilter{Search: "CHARLIE"}))
+		assert.Equal(t, []string{"delta", "alpha", "charlie", "bravo"}, list(dtos.ShURLFilter{Search: "page "}))
+		assert.Empty(t, list(dtos.ShURLFilter{Search: "missing"}))
+	})
+
+	t.Run("invalid parameters are rejected", func(t *testing.T) {
+		_, next, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{Limit: 1})
+		require.NoError(t, err)
+		require.NotNil(t, next)
+
+		for reason, filter := range map[string]dtos.ShURLFilter{
+			services.URLReasonInvalidSort:   {Sort: "title"},
+			services.URLReasonInvalidLimit:  {Limit: services.MaxPageSize + 1},
+			services.URLReasonInvalidCursor: {Sort: dtos.SortAlias, After: next},
+		} {
+			_, _, err := service.GetAllShURLsByUserID(ctx, "user1", filter)
+
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), reason)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Equal(t, reason, httpErr.Reason)
+		}
+	})
+}
+
+// blockingRepository - репозиторий, в котором создание ShURL блокируется до закрытия release
+type blockingRepository struct {
+	*inmemory.InMemoryRepository
+	started chan struct{}
+	release chan struct{}
+}
+
+// Create - дождаться release и создать ShURL
+func (r *blockingRepository) Create(ctx context.Context, shURL *entities.ShURL) error {
+	r.started <- struct{}{}
+	<-r.release
+	return r.InMemoryRepository.Create(ctx, shURL)
+}
+
+// slowRepository - репозиторий, создание ShURL в котором занимает delay
+type slowRepository struct {
+	*inmemory.InMemoryRepository
+	delay time.Duration
+}
+
+// Create - подождать delay и создать ShURL
+func (r *slowRepository) Create(ctx context.Context, shURL *entities.ShURL) error {
+	time.Sleep(r.delay)
+	return r.InMemoryRepository.Create(ctx, shURL)
+}
+
+// CreateMany - подождать delay и создать пачку ShURL
+func (r *slowRepository) CreateMany(ctx context.Context, shURLs []entities.ShURL) error {
+	time.Sleep(r.delay)
+	return r.InMemoryRepository.CreateMany(ctx, shURLs)
+}
+
+// TestShURLService_Workers - проверка параллельной обработки задач пулом воркеров
+func TestShURLService_Workers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads are not blocked by slow writes", func(t *testing.T) {
+		repo := &blockingRepository{
+			InMemoryRepository: inmemory.NewInMemoryRepository(),
+			started:            make(chan struct{}, 1),
+			release:            make(chan struct{}),
+		}
+		require.NoError(t, repo.InMemoryRepository.Create(ctx, &entities.ShURL{Token: "existing", LongURL: "https://example.com", CreatedBy: "user1"}))
+
+		service := services.NewShURLService(repo, services.WithWorkers(2))
+		defer service.Shutdown()
+
+		created := make(chan error, 1)
+		go func() {
+			_, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/slow", CreatedBy: "user2"})
+			created <- err
+		}()
+		<-repo.started
+
+		shURL, err := service.Get(ctx, "existing")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", shURL.LongURL)
+
+		close(repo.release)
+		require.NoError(t, <-created)
+	})
+
+	t.Run("concurrent creates of one user are deduplicated", func(t *testing.T) {
+		service := services.NewShURLService(inmemory.NewInMemoryRepository(), services.WithWorkers(4))
+		defer service.Shutdown()
+
+		var wg sync.WaitGroup
+		tokens := make(chan string, 20)
+		for i := 0; i < cap(tokens); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				shURL, _ := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/same", CreatedBy: "user1"})
+				tokens <- shURL.Token
+			}()
+		}
+		wg.Wait()
+		close(tokens)
+
+		unique := make(map[string]struct{})
+		for token := range tokens {
+			unique[token] = struct{}{}
+		}
+		assert.Len(t, unique, 1)
+	})
+
+	t.Run("concurrent global creates of equivalent URLs are deduplicated", func(t *testing.T) {
+		// Медленная запись: без упорядочивания параллельные создания успевают пройти проверку дублей
+		repo := &slowRepository{InMemoryRepository: inmemory.NewInMemoryRepository(), delay: 10 * time.Millisecond}
+		service := services.NewShURLService(repo,
+			services.WithWorkers(4),
+			services.WithGlobalDeduplication(true),
+			services.WithURLNormalization(services.URLNormalization{StripDefaultPort: true}),
+		)
+		defer service.Shutdown()
+
+		// Разные записи одного URL упорядочиваются по нормализованному виду и попадают в одну очередь
+		variants := []string{"https://example.com/same", " https://example.com/same", "https://example.com:443/same", "https://EXAMPLE.com:443/same "}
+
+		var wg sync.WaitGroup
+		tokens := make(chan string, 20)
+		for i := 0; i < cap(tokens); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				shURL, _ := service.Create(ctx, dtos.NewShURL{LongURL: variants[i%len(variants)], CreatedBy: fmt.Sprintf("user%d", i)})
+				tokens <- shURL.Token
+			}(i)
+		}
+		wg.Wait()
+		close(tokens)
+
+		unique := make(map[string]struct{})
+		for token := range tokens {
+			unique[token] = struct{}{}
+		}
+		assert.Len(t, unique, 1)
+	})
+
+	t.Run("concurrent global batch and single creates of one URL are deduplicated", func(t *testing.T) {
+		repo := &slowRepository{InMemoryRepository: inmemory.NewInMemoryRepository(), delay: 10 * time.Millisecond}
+		service := services.NewShURLService(repo, services.WithWorkers(4), services.WithGlobalDeduplication(true))
+		defer service.Shutdown()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/shared", CreatedBy: fmt.Sprintf("single%d", i)})
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				// Первый элемент пачки - другой URL: ключ пачки не должен зависеть от её первого элемента
+				service.CreateMany(ctx, []dtos.NewShURL{
+					{LongURL: fmt.Sprintf("https://example.com/batch/%d", i), CreatedBy: fmt.Sprintf("batch%d", i)},
+					{LongURL: "https://example.com/shared", CreatedBy: fmt.Sprintf("batch%d", i)},
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		shared, err := repo.GetByLongURL(ctx, "https://example.com/shared")
+		require.NoError(t, err)
+		assert.Len(t, shared, 1)
+	})
+}
+
+// TestShURLService_AfterShutdown - проверка ответа сервиса на запросы после остановки
+func TestShURLService_AfterShutdown(t *testing.T) {
+	ctx := context.Background()
+	service := services.NewShURLService(inmemory.NewInMemoryRepository())
+
+	shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	service.Shutdown()
+
+	assertUnavailable := func(t *testing.T, err error) {
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		got, err := service.Get(ctx, shURL.Token)
+		assert.Nil(t, got)
+		assertUnavailable(t, err)
+	})
+
+	t.Run("get all", func(t *testing.T) {
+		all, err := service.GetAll(ctx)
+		assert.Nil(t, all)
+		assertUnavailable(t, err)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		created, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/new", CreatedBy: "user1"})
+		assert.Nil(t, created)
+		assertUnavailable(t, err)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated, err := service.Update(ctx, dtos.UpdateShURL{Token: shURL.Token, UpdatedBy: "user1", LongURL: "https://example.com/updated"})
+		assert.Nil(t, updated)
+		assertUnavailable(t, err)
+	})
+}
+
 // TestShURLService_Delete - проверка удаления ShURL
 func TestShURLService_Delete(t *testing.T) {
 	mockRepo := inmemory.NewInMemoryRepository()
-	service := services.NewShURLService(mockRepo)
+	service := services.NewShURLService(mockRepo, services.WithDeletionBatching(100, 20*time.Millisecond))
 	ctx := context.Background()
 
 	// Setup test data
@@ -149,19 +1578,266 @@ func TestShURLService_Delete(t *testing.T) {
 		err := service.Delete(ctx, []string{tokens[0]}, "user1")
 		require.NoError(t, err)
 
-		// Verify deletion
-		shURLs, err := service.GetAllShURLsByUserID(ctx, "user1")
-		require.NoError(t, err)
-		assert.Len(t, shURLs, 1)
+		// Удаление асинхронное - ждём сброса накопленных запросов по таймеру
+		require.Eventually(t, func() bool {
+			shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{})
+			return err == nil && len(shURLs) == 1
+		}, time.Second, 10*time.Millisecond)
 	})
 
 	t.Run("delete URLs by wrong user", func(t *testing.T) {
-		err := service.Delete(ctx, []string{tokens[2]}, "user1")
+		repo := inmemory.NewInMemoryRepository()
+		service := services.NewShURLService(repo, services.WithDeletionBatching(100, time.Hour))
+
+		own, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/own", CreatedBy: "user1"})
+		require.NoError(t, err)
+		foreign, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/foreign", CreatedBy: "user2"})
 		require.NoError(t, err)
 
-		// URL should still exist since user1 doesn't own it
-		shURL, err := service.Get(ctx, tokens[2])
+		require.NoError(t, service.Delete(ctx, []string{own.Token, foreign.Token}, "user1"))
+
+		// Остановка сервиса сбрасывает накопленные запросы на удаление
+		service.Shutdown()
+
+		_, err = repo.Get(ctx, own.Token)
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusGone, httpErr.Code)
+
+		// Ссылка другого пользователя не удалена
+		shURL, err := repo.Get(ctx, foreign.Token)
 		require.NoError(t, err)
 		assert.NotNil(t, shURL)
 	})
+
+	t.Run("pending deletions are flushed on shutdown", func(t *testing.T) {
+		repo := inmemory.NewInMemoryRepository()
+		service := services.NewShURLService(repo, services.WithDeletionBatching(100, time.Hour))
+
+		shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+		require.NoError(t, err)
+		require.NoError(t, service.Delete(ctx, []string{shURL.Token}, "user1"))
+
+		service.Shutdown()
+
+		_, err = repo.Get(ctx, shURL.Token)
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusGone, httpErr.Code)
+	})
+
+	t.Run("batch is flushed when size is reached", func(t *testing.T) {
+		repo := inmemory.NewInMemoryRepository()
+		service := services.NewShURLService(repo, services.WithDeletionBatching(2, time.Hour))
+		defer service.Shutdown()
+
+		first, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/1", CreatedBy: "user1"})
+		require.NoError(t, err)
+		second, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/2", CreatedBy: "user2"})
+		require.NoError(t, err)
+
+		require.NoError(t, service.Delete(ctx, []string{first.Token}, "user1"))
+		require.NoError(t, service.Delete(ctx, []string{second.Token}, "user2"))
+
+		require.Eventually(t, func() bool {
+			_, err1 := repo.Get(ctx, first.Token)
+			_, err2 := repo.Get(ctx, second.Token)
+			return err1 != nil && err2 != nil
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+// fakeClock - управляемый источник времени для тестов
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now - текущее время часов
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance - перевести часы вперёд
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestShURLService_Expiry - проверка срока жизни ShURL и фоновой очистки
+func TestShURLService_Expiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo, services.WithClock(clock.Now), services.WithExpirySweepInterval(0))
+	ctx := context.Background()
+
+	withTTL, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/ttl",
+		CreatedBy: "user1",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, withTTL.ExpiresAt)
+	assert.Equal(t, clock.Now().Add(time.Hour), *withTTL.ExpiresAt)
+
+	expiresAt := clock.Now().Add(3 * time.Hour)
+	withExpiresAt, err := service.Create(ctx, dtos.NewShURL{
+		LongURL:   "https://example.com/expires-at",
+		CreatedBy: "user1",
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+
+	t.Run("not expired link is available", func(t *testing.T) {
+		shURL, err := service.Get(ctx, withTTL.Token)
+		require.NoError(t, err)
+		assert.Equal(t, withTTL.LongURL, shURL.LongURL)
+	})
+
+	t.Run("expired link returns gone", func(t *testing.T) {
+		clock.Advance(2 * time.Hour)
+
+		_, err := service.Get(ctx, withTTL.Token)
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusGone, httpErr.Code)
+
+		_, err = service.Get(ctx, withExpiresAt.Token)
+		require.NoError(t, err)
+	})
+
+	t.Run("sweeper marks expired links as deleted", func(t *testing.T) {
+		require.NoError(t, service.SweepExpired(ctx))
+
+		shURLs, _, err := service.GetAllShURLsByUserID(ctx, "user1", dtos.ShURLFilter{})
+		require.NoError(t, err)
+		require.Len(t, shURLs, 1)
+		assert.Equal(t, withExpiresAt.Token, shURLs[0].Token)
+	})
+
+	t.Run("invalid expiration returns bad request", func(t *testing.T) {
+		past := clock.Now().Add(-time.Minute)
+		invalid := []dtos.NewShURL{
+			{LongURL: "https://example.com/past", CreatedBy: "user1", ExpiresAt: &past},
+			{LongURL: "https://example.com/negative", CreatedBy: "user1", TTL: -time.Minute},
+			{LongURL: "https://example.com/both", CreatedBy: "user1", TTL: time.Minute, ExpiresAt: &expiresAt},
+		}
+
+		for _, newURL := range invalid {
+			_, err := service.Create(ctx, newURL)
+			var httpErr *customerrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		}
+	})
+}
+
+// TestShURLService_ClickStats - проверка записи и получения статистики переходов
+func TestShURLService_ClickStats(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)}
+	mockRepo := inmemory.NewInMemoryRepository()
+	service := services.NewShURLService(mockRepo,
+		services.WithClock(clock.Now),
+		services.WithClickStore(mockRepo),
+		services.WithExpirySweepInterval(0),
+	)
+	ctx := context.Background()
+
+	shURL, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com", CreatedBy: "user1"})
+	require.NoError(t, err)
+
+	service.RecordClick(entities.Click{Token: shURL.Token, IP: "203.0.113.0"})
+	service.RecordClick(entities.Click{Token: shURL.Token, IP: "203.0.113.0"})
+	clock.Advance(2 * time.Hour)
+	service.RecordClick(entities.Click{Token: shURL.Token, IP: "198.51.100.0"})
+
+	t.Run("stats of another user's shurl are forbidden", func(t *testing.T) {
+		_, err := service.GetClickStats(ctx, shURL.Token, "user2")
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusForbidden, httpErr.Code)
+	})
+
+	t.Run("stats of an exhausted shurl are available", func(t *testing.T) {
+		limited, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/limited", CreatedBy: "user1", MaxClicks: 1})
+		require.NoError(t, err)
+		require.NoError(t, service.ConsumeClick(ctx, limited))
+
+		_, err = service.Resolve(ctx, limited.Token)
+		require.Error(t, err, "exhausted link must not be resolved")
+
+		stats, err := service.GetClickStats(ctx, limited.Token, "user1")
+		require.NoError(t, err)
+		assert.NotNil(t, stats)
+
+		_, err = service.GetClickStats(ctx, limited.Token, "user2")
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusForbidden, httpErr.Code)
+	})
+
+	t.Run("stats of an expired shurl are available", func(t *testing.T) {
+		expiresAt := clock.Now().Add(time.Minute)
+		expiring, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/expiring", CreatedBy: "user1", ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		clock.Advance(time.Hour)
+		_, err = service.Resolve(ctx, expiring.Token)
+		require.Error(t, err, "expired link must not be resolved")
+
+		stats, err := service.GetClickStats(ctx, expiring.Token, "user1")
+		require.NoError(t, err)
+		assert.NotNil(t, stats)
+	})
+
+	t.Run("stats of a swept expired shurl are available", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+		repo := inmemory.NewInMemoryRepository()
+		service := services.NewShURLService(repo,
+			services.WithClock(clock.Now),
+			services.WithClickStore(repo),
+			services.WithExpirySweepInterval(10*time.Millisecond),
+		)
+		defer service.Shutdown()
+
+		expiresAt := clock.Now().Add(time.Minute)
+		expiring, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/swept", CreatedBy: "user1", ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+		deleted, err := service.Create(ctx, dtos.NewShURL{LongURL: "https://example.com/deleted", CreatedBy: "user1"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, []string{deleted.Token}, "user1"))
+
+		// Дожидаемся, пока фоновая очистка пометит истёкшую ссылку удалённой
+		clock.Advance(time.Hour)
+		require.Eventually(t, func() bool {
+			_, err := repo.Get(ctx, expiring.Token)
+			var httpErr *customerrors.HTTPError
+			return errors.As(err, &httpErr) && httpErr.Code == http.StatusGone
+		}, time.Second, 10*time.Millisecond)
+
+		stats, err := service.GetClickStats(ctx, expiring.Token, "user1")
+		require.NoError(t, err)
+		assert.NotNil(t, stats)
+
+		// Статистика удалённой пользователем ссылки недоступна
+		_, err = service.GetClickStats(ctx, deleted.Token, "user1")
+		var httpErr *customerrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusGone, httpErr.Code)
+	})
+
+	t.Run("pending clicks are flushed on shutdown", func(t *testing.T) {
+		service.Shutdown()
+
+		stats, err := mockRepo.GetClickStats(ctx, shURL.Token)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.Total)
+		require.Len(t, stats.Daily, 2)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), stats.Daily[0].Day)
+		assert.Equal(t, int64(2), stats.Daily[0].Clicks)
+		assert.Equal(t, int64(1), stats.Daily[1].Clicks)
+	})
 }
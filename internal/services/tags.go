@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+)
+
+// Причины отказа при некорректных метках и папке ссылки
+const (
+	URLReasonInvalidTags   = "invalid_tags"
+	URLReasonInvalidFolder = "invalid_folder"
+)
+
+// Ограничения на метки и папку одной ссылки
+const (
+	maxTags         = 20
+	maxFolderLength = 64
+)
+
+// tagPattern - допустимая метка (после приведения к нижнему регистру): буквы, цифры, "_" и "-"
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// invalidFolderError - кастомная ошибка некорректной папки
+var invalidFolderError = customerrors.NewValidationError(URLReasonInvalidFolder, errors.New("folder must not exceed 64 characters or contain control characters"))
+
+// invalidTagsError - ошибка проверки меток ссылки
+func invalidTagsError(format string, args ...any) error {
+	return customerrors.NewValidationError(URLReasonInvalidTags, fmt.Errorf(format, args...))
+}
+
+// resolveTags - проверить метки ссылки и привести их к каноническому виду:
+// без пробелов по краям, в нижнем регистре, без повторов, по возрастанию
+func resolveTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	resolved := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if !tagPattern.MatchString(tag) {
+			return nil, invalidTagsError("tag %q must be 1 to 32 letters, digits, '_' or '-'", tag)
+		}
+		resolved = append(resolved, tag)
+	}
+
+	slices.Sort(resolved)
+	resolved = slices.Compact(resolved)
+	if len(resolved) > maxTags {
+		return nil, invalidTagsError("link must not have more than %d tags", maxTags)
+	}
+
+	return resolved, nil
+}
+
+// normalizeTag - привести метку к каноническому виду (метки сравниваются без учёта регистра)
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// resolveFolder - проверить папку ссылки и убрать пробелы по краям ("" - ссылка вне папок)
+func resolveFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if utf8.RuneCountInString(folder) > maxFolderLength || strings.ContainsFunc(folder, unicode.IsControl) {
+		return "", invalidFolderError
+	}
+
+	return folder, nil
+}
+
+// normalizeFilter - привести метки и папку фильтра к тому виду, в котором они хранятся у ссылок
+func normalizeFilter(filter dtos.ShURLFilter) dtos.ShURLFilter {
+	normalized := dtos.ShURLFilter{Folder: strings.TrimSpace(filter.Folder)}
+	for _, tag := range filter.Tags {
+		if tag = normalizeTag(tag); tag != "" {
+			normalized.Tags = append(normalized.Tags, tag)
+		}
+	}
+
+	return normalized
+}
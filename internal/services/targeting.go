@@ -0,0 +1,243 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// URLReasonInvalidTargeting - причина отказа: некорректное правило выбора адреса назначения
+const URLReasonInvalidTargeting = "invalid_targeting"
+
+// maxTargetingRules - максимальное количество правил выбора адреса назначения у одной ссылки
+const maxTargetingRules = 20
+
+// tooManyTargetingRulesError - ошибка превышения количества правил (относится ко всему списку, а не к отдельному правилу)
+var tooManyTargetingRulesError = customerrors.NewValidationError(URLReasonInvalidTargeting,
+	fmt.Errorf("at most %d targeting rules are allowed", maxTargetingRules))
+
+// targetingTimeLayout - формат границ окна времени суток в правилах
+const targetingTimeLayout = "15:04"
+
+// Семейства User-Agent, по которым выбирается адрес назначения
+const (
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceWindows = "windows"
+	DeviceMacOS   = "macos"
+	DeviceLinux   = "linux"
+)
+
+// targetingLocations - кеш часовых поясов правил (загрузка часового пояса читает tzdata, а правила проверяются на каждом переходе)
+var targetingLocations sync.Map
+
+// invalidTargetingError - ошибка проверки правила выбора адреса назначения (index - номер правила, начиная с 0)
+func invalidTargetingError(index int, format string, args ...any) error {
+	return &customerrors.HTTPError{
+		Code:    http.StatusBadRequest,
+		Err:     fmt.Errorf("targeting rule %d: "+format, append([]any{index}, args...)...),
+		Reason:  URLReasonInvalidTargeting,
+		Details: map[string]string{"rule": strconv.Itoa(index)},
+	}
+}
+
+// resolveTargeting - проверить правила выбора адреса назначения новой ссылки и нормализовать их адреса
+func (s *ShURLService) resolveTargeting(rules []entities.TargetingRule) ([]entities.TargetingRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	if len(rules) > maxTargetingRules {
+		return nil, tooManyTargetingRulesError
+	}
+
+	resolved := make([]entities.TargetingRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Device = strings.ToLower(strings.TrimSpace(rule.Device))
+		switch rule.Device {
+		case "", DeviceIOS, DeviceAndroid, DeviceWindows, DeviceMacOS, DeviceLinux:
+		default:
+			return nil, invalidTargetingError(i, "unknown device %q", rule.Device)
+		}
+
+		rule.Language = strings.ToLower(strings.TrimSpace(rule.Language))
+
+		if rule.Header != "" {
+			rule.Header = http.CanonicalHeaderKey(strings.TrimSpace(rule.Header))
+		} else if rule.HeaderValue != "" {
+			return nil, invalidTargetingError(i, "header value requires header name")
+		}
+
+		if (rule.From == "") != (rule.To == "") {
+			return nil, invalidTargetingError(i, "time window requires both from and to")
+		}
+		if rule.From != "" {
+			if _, err := time.Parse(targetingTimeLayout, rule.From); err != nil {
+				return nil, invalidTargetingError(i, "from must be HH:MM")
+			}
+			if _, err := time.Parse(targetingTimeLayout, rule.To); err != nil {
+				return nil, invalidTargetingError(i, "to must be HH:MM")
+			}
+			if _, err := targetingLocation(rule.TimeZone); err != nil {
+				return nil, invalidTargetingError(i, "unknown time zone %q", rule.TimeZone)
+			}
+		} else if rule.TimeZone != "" {
+			return nil, invalidTargetingError(i, "time zone requires time window")
+		}
+
+		if rule.Device == "" && rule.Language == "" && rule.Header == "" && rule.From == "" {
+			return nil, invalidTargetingError(i, "rule has no conditions")
+		}
+
+		longURL, err := s.normalizeLongURL(rule.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.checkDestination(longURL); err != nil {
+			return nil, err
+		}
+
+		rule.URL = longURL
+		resolved = append(resolved, rule)
+	}
+
+	return resolved, nil
+}
+
+// matchTargeting - первое правило, все условия которого выполнены для запроса (nil - таких нет)
+func matchTargeting(rules []entities.TargetingRule, header http.Header, now time.Time) *entities.TargetingRule {
+	for i := range rules {
+		if matchesRule(&rules[i], header, now) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+// matchesRule - выполнены ли все условия правила для запроса
+func matchesRule(rule *entities.TargetingRule, header http.Header, now time.Time) bool {
+	if rule.Device != "" && userAgentFamily(header.Get("User-Agent")) != rule.Device {
+		return false
+	}
+
+	if rule.Language != "" && !acceptsLanguage(header.Get("Accept-Language"), rule.Language) {
+		return false
+	}
+
+	if rule.Header != "" {
+		values, ok := header[rule.Header]
+		if !ok || (rule.HeaderValue != "" && !containsFold(values, rule.HeaderValue)) {
+			return false
+		}
+	}
+
+	if rule.From != "" && !inTimeWindow(rule, now) {
+		return false
+	}
+
+	return true
+}
+
+// userAgentFamily - семейство устройства по User-Agent ("" - не определено).
+// Порядок проверок важен: User-Agent iOS содержит "Mac OS X", а Android - "Linux"
+func userAgentFamily(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return DeviceIOS
+	case strings.Contains(ua, "android"):
+		return DeviceAndroid
+	case strings.Contains(ua, "windows"):
+		return DeviceWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return DeviceMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return DeviceLinux
+	default:
+		return ""
+	}
+}
+
+// acceptsLanguage - содержит ли Accept-Language язык language (язык "en" совпадает и с региональными вариантами, например "en-US").
+// Языки с q=0 явно отклонены клиентом и не учитываются
+func acceptsLanguage(acceptLanguage, language string) bool {
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == language || strings.HasPrefix(tag, language+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold - содержит ли values значение value без учёта регистра
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inTimeWindow - попадает ли момент now в окно времени суток правила (окно может переходить через полночь)
+func inTimeWindow(rule *entities.TargetingRule, now time.Time) bool {
+	location, err := targetingLocation(rule.TimeZone)
+	if err != nil {
+		return false
+	}
+
+	from, errFrom := time.Parse(targetingTimeLayout, rule.From)
+	to, errTo := time.Parse(targetingTimeLayout, rule.To)
+	if errFrom != nil || errTo != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	start := from.Hour()*60 + from.Minute()
+	end := to.Hour()*60 + to.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+
+	return minute >= start || minute < end
+}
+
+// targetingLocation - часовой пояс правила ("" - UTC)
+func targetingLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	if cached, ok := targetingLocations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	targetingLocations.Store(name, location)
+	return location, nil
+}
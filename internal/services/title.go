@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+)
+
+// URLReasonTitleTooLong - причина отказа: заголовок ссылки слишком длинный
+const URLReasonTitleTooLong = "title_too_long"
+
+// maxTitleLength - максимальная длина заголовка ссылки в символах
+const maxTitleLength = 256
+
+// titleTooLongError - кастомная ошибка слишком длинного заголовка
+var titleTooLongError = customerrors.NewValidationError(URLReasonTitleTooLong, errors.New("title must not exceed 256 characters"))
+
+// validateTitle - проверить заголовок новой ссылки ("" - ссылка без заголовка)
+func validateTitle(title string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return titleTooLongError
+	}
+
+	return nil
+}
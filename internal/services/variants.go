@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/JustScorpio/urlshortener/internal/customerrors"
+	"github.com/JustScorpio/urlshortener/internal/models/entities"
+)
+
+// URLReasonInvalidVariants - причина отказа: некорректные варианты A/B-теста
+const URLReasonInvalidVariants = "invalid_variants"
+
+// Ограничения на варианты A/B-теста одной ссылки
+const (
+	minVariants = 2
+	maxVariants = 10
+	// maxVariantWeight - максимальный суммарный вес вариантов (и, значит, вес одного варианта)
+	maxVariantWeight = 10000
+)
+
+// variantIDPattern - допустимый ID варианта (попадает в куку и статистику переходов)
+var variantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// invalidVariantsError - ошибка проверки вариантов A/B-теста (index - номер варианта, начиная с 0; -1 - ошибка всего списка)
+func invalidVariantsError(index int, format string, args ...any) error {
+	err := &customerrors.HTTPError{
+		Code:   http.StatusBadRequest,
+		Err:    fmt.Errorf(format, args...),
+		Reason: URLReasonInvalidVariants,
+	}
+
+	if index >= 0 {
+		err.Err = fmt.Errorf("variant %d: %w", index, err.Err)
+		err.Details = map[string]string{"variant": strconv.Itoa(index)}
+	}
+
+	return err
+}
+
+// resolveVariants - проверить варианты A/B-теста новой ссылки и нормализовать их адреса.
+// Вариантам без ID присваиваются ID по порядку: a, b, c...
+func (s *ShURLService) resolveVariants(variants []entities.Variant) ([]entities.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+
+	if len(variants) < minVariants || len(variants) > maxVariants {
+		return nil, invalidVariantsError(-1, "a/b test must have from %d to %d variants", minVariants, maxVariants)
+	}
+
+	resolved := make([]entities.Variant, 0, len(variants))
+	seen := make(map[string]bool, len(variants))
+	totalWeight := 0
+	for i, variant := range variants {
+		if variant.ID == "" {
+			variant.ID = string(rune('a' + i))
+		}
+
+		if !variantIDPattern.MatchString(variant.ID) {
+			return nil, invalidVariantsError(i, "id must be 1-32 letters, digits, '-' or '_'")
+		}
+		if seen[variant.ID] {
+			return nil, invalidVariantsError(i, "duplicate id %q", variant.ID)
+		}
+		seen[variant.ID] = true
+
+		if variant.Weight < 0 || variant.Weight > maxVariantWeight {
+			return nil, invalidVariantsError(i, "weight must be from 0 to %d", maxVariantWeight)
+		}
+		totalWeight += variant.Weight
+
+		longURL, err := s.normalizeLongURL(variant.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.checkDestination(longURL); err != nil {
+			return nil, err
+		}
+
+		variant.URL = longURL
+		resolved = append(resolved, variant)
+	}
+
+	if totalWeight == 0 {
+		return nil, invalidVariantsError(-1, "at least one variant must have positive weight")
+	}
+	if totalWeight > maxVariantWeight {
+		return nil, invalidVariantsError(-1, "total weight of variants must not exceed %d", maxVariantWeight)
+	}
+
+	return resolved, nil
+}
+
+// pickVariant - выбрать вариант A/B-теста: закреплённый за посетителем (stickyID), если он ещё показывается,
+// иначе - случайный с вероятностью, пропорциональной весу (nil - у ссылки нет вариантов)
+func pickVariant(variants []entities.Variant, stickyID string) *entities.Variant {
+	totalWeight := 0
+	for i := range variants {
+		if stickyID != "" && variants[i].ID == stickyID && variants[i].Weight > 0 {
+			return &variants[i]
+		}
+		totalWeight += variants[i].Weight
+	}
+
+	if totalWeight <= 0 {
+		return nil
+	}
+
+	n := rand.IntN(totalWeight)
+	for i := range variants {
+		if n < variants[i].Weight {
+			return &variants[i]
+		}
+		n -= variants[i].Weight
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"hash/fnv"
+	"runtime"
+
+	"github.com/JustScorpio/urlshortener/internal/models/dtos"
+)
+
+// taskQueueSize - размер буфера каждой очереди задач
+const taskQueueSize = 300
+
+// defaultWorkers - количество воркеров по умолчанию
+func defaultWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// startWorkers - создать очереди задач и запустить пул воркеров.
+// Чтения не меняют данные, поэтому воркеры чтения разбирают одну общую очередь параллельно.
+// Записи распределяются по очередям по ключу упорядочивания, и у каждой очереди ровно один воркер:
+// записи с одним ключом выполняются строго в порядке поступления, записи с разными ключами - параллельно
+func (s *ShURLService) startWorkers() {
+	s.readQueue = make(chan Task, taskQueueSize)
+	s.writeQueues = make([]chan Task, s.workers)
+
+	s.workersDone.Add(2 * s.workers)
+	for i := range s.writeQueues {
+		s.writeQueues[i] = make(chan Task, taskQueueSize)
+
+		go s.taskProcessor(s.readQueue)
+		go s.taskProcessor(s.writeQueues[i])
+	}
+}
+
+// stopWorkers - закрыть очереди задач и дождаться завершения воркеров
+func (s *ShURLService) stopWorkers() {
+	close(s.readQueue)
+	for _, queue := range s.writeQueues {
+		close(queue)
+	}
+
+	s.workersDone.Wait()
+}
+
+// queueFor - выбрать очередь для задачи
+func (s *ShURLService) queueFor(task Task) chan<- Task {
+	switch task.Type {
+	case TaskGetAll, TaskGet, TaskGetByUserID, TaskGetStored:
+		return s.readQueue
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(s.orderingKey(task)))
+
+	return s.writeQueues[hash.Sum32()%uint32(len(s.writeQueues))]
+}
+
+// globalCreateKey - ключ упорядочивания всех созданий ссылок при глобальной дедупликации
+const globalCreateKey = "create"
+
+// orderingKey - ключ, записи с которым выполняются последовательно.
+// Создание и изменение ссылок одного пользователя упорядочены, чтобы проверка дублей и владельца
+// не пересекалась с параллельной записью. При глобальной дедупликации дубли ищутся среди всех пользователей,
+// а пачка может содержать любые URL, поэтому все создания (одиночные и пачками) выполняются одной очередью.
+// Списания переходов упорядочиваются по токену, чтобы переходы по разным ссылкам не ждали друг друга
+func (s *ShURLService) orderingKey(task Task) string {
+	switch task.Type {
+	case TaskCreate:
+		if s.globalDedup {
+			return globalCreateKey
+		}
+		return task.Payload.(*dtos.NewShURL).CreatedBy
+	case TaskConsumeClick:
+		return task.Payload.(string)
+	case TaskCreateMany:
+		if s.globalDedup {
+			return globalCreateKey
+		}
+		// Пачка содержит ссылки одного пользователя
+		if newURLs := task.Payload.([]dtos.NewShURL); len(newURLs) > 0 {
+			return newURLs[0].CreatedBy
+		}
+		return ""
+	case TaskUpdate:
+		return task.Payload.(*dtos.UpdateShURL).UpdatedBy
+	default:
+		return ""
+	}
+}
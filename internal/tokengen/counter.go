@@ -0,0 +1,61 @@
+package tokengen
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// CounterGenerator - токены из монотонного счётчика в base62 (короткие и без коллизий между собой)
+type CounterGenerator struct {
+	counter   atomic.Uint64
+	minLength int
+}
+
+// NewCounterGenerator - инициализация генератора. start - начальное значение счётчика,
+// minLength - минимальная длина токена (короткие значения дополняются нулями слева)
+func NewCounterGenerator(start uint64, minLength int) (*CounterGenerator, error) {
+	if err := validateLength(minLength); err != nil {
+		return nil, err
+	}
+
+	g := &CounterGenerator{minLength: minLength}
+	g.counter.Store(start)
+
+	return g, nil
+}
+
+// Seed - продолжить счёт после наибольшего из уже выданных токенов tokens (вызывается при запуске:
+// сам счётчик между перезапусками не сохраняется). Учитываются только токены в формате генератора,
+// поэтому псевдонимы из одних цифр и латинских букв длиной от minLength тоже сдвигают счётчик
+func (g *CounterGenerator) Seed(tokens []string) {
+	for _, token := range tokens {
+		n, ok := decodeBase62(token)
+		if !ok || g.format(n) != token {
+			continue
+		}
+
+		for current := g.counter.Load(); n > current; current = g.counter.Load() {
+			if g.counter.CompareAndSwap(current, n) {
+				break
+			}
+		}
+	}
+}
+
+// Generate - получить следующий токен.
+// Токены, выданные другими экземплярами сервиса или удалённые до запуска, в Seed не учитываются,
+// поэтому при коллизии счётчик сдвигается экспоненциально (2^attempt), чтобы за несколько попыток проскочить занятый диапазон
+func (g *CounterGenerator) Generate(_ string, attempt int) (string, error) {
+	step := uint64(1) << min(attempt, 32)
+	return g.format(g.counter.Add(step)), nil
+}
+
+// format - токен для значения счётчика n (короткие значения дополняются нулями слева до minLength)
+func (g *CounterGenerator) format(n uint64) string {
+	token := encodeBase62(n)
+	if len(token) < g.minLength {
+		token = strings.Repeat(string(base62Alphabet[0]), g.minLength-len(token)) + token
+	}
+
+	return token
+}
@@ -0,0 +1,48 @@
+package tokengen
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"math/big"
+)
+
+// deterministicHashAttempts - количество попыток, на которых токен зависит только от longURL
+const deterministicHashAttempts = 4
+
+// HashGenerator - детерминированные токены из хэша длинного URL
+// Один и тот же URL всегда получает один и тот же токен (на одинаковом номере попытки)
+type HashGenerator struct {
+	length int
+}
+
+// NewHashGenerator - инициализация генератора
+func NewHashGenerator(length int) (*HashGenerator, error) {
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+
+	return &HashGenerator{length: length}, nil
+}
+
+// Generate - получить токен как префикс base62-представления SHA-512 от longURL.
+// Повторная генерация для того же URL дала бы тот же токен, поэтому при коллизии префикс удлиняется на каждой попытке.
+// Если и удлинённые префиксы заняты (тот же URL сокращён несколькими пользователями), к URL добавляется случайная соль
+func (g *HashGenerator) Generate(longURL string, attempt int) (string, error) {
+	data := []byte(longURL)
+	length := g.length + attempt
+
+	if attempt >= deterministicHashAttempts {
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		data = append(data, salt...)
+		length = grownLength(g.length, attempt)
+	}
+
+	sum := sha512.Sum512(data)
+	encoded := new(big.Int).SetBytes(sum[:]).Text(62) // ~86 символов
+
+	return encoded[:min(length, maxLength, len(encoded))], nil
+}
@@ -0,0 +1,48 @@
+package tokengen
+
+import (
+	"strings"
+
+	"github.com/jaevor/go-nanoid"
+)
+
+// RandomGenerator - случайные токены (nanoid) с настраиваемым алфавитом и длиной
+type RandomGenerator struct {
+	alphabet string
+	length   int
+}
+
+// NewRandomGenerator - инициализация генератора случайных токенов
+func NewRandomGenerator(alphabet string, length int) (*RandomGenerator, error) {
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+
+	if !urlSafeAlphabet.MatchString(alphabet) || !hasDistinctChars(alphabet) {
+		return nil, errInvalidAlphabet
+	}
+
+	if _, err := nanoid.CustomASCII(alphabet, length); err != nil {
+		return nil, errInvalidAlphabet
+	}
+
+	return &RandomGenerator{
+		alphabet: alphabet,
+		length:   length,
+	}, nil
+}
+
+// Generate - сгенерировать случайный токен. При повторных коллизиях длина токена увеличивается
+func (g *RandomGenerator) Generate(_ string, attempt int) (string, error) {
+	generate, err := nanoid.CustomASCII(g.alphabet, grownLength(g.length, attempt))
+	if err != nil {
+		return "", err
+	}
+
+	return generate(), nil // Пример: "EwHXdJfB"
+}
+
+// hasDistinctChars - содержит ли алфавит хотя бы два различных символа
+func hasDistinctChars(alphabet string) bool {
+	return strings.Trim(alphabet, alphabet[:1]) != ""
+}
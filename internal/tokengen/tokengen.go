@@ -0,0 +1,117 @@
+// Пакет tokengen содержит стратегии генерации токенов укороченных ссылок
+package tokengen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Стратегии генерации токенов, выбираемые конфигурацией
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHash    = "hash"
+	StrategyWords   = "words"
+)
+
+// Параметры генерации по умолчанию
+const (
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DefaultLength   = 8
+)
+
+// Ограничения на длину токена (токен хранится в колонке VARCHAR(64))
+const (
+	minLength = 4
+	maxLength = 64
+)
+
+// base62Alphabet - алфавит для кодирования чисел и хэшей
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// urlSafeAlphabet - символы, допустимые в токене без экранирования в пути URL
+var urlSafeAlphabet = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Кастомные типы ошибок, возвращаемых некоторыми из функций пакета
+var (
+	errUnknownStrategy = errors.New("unknown token strategy")
+	errInvalidAlphabet = errors.New("token alphabet must contain at least 2 distinct url-safe characters")
+	errInvalidLength   = fmt.Errorf("token length must be between %d and %d", minLength, maxLength)
+)
+
+// TokenGenerator - стратегия генерации токенов ShURL
+type TokenGenerator interface {
+	// Generate - сгенерировать токен для longURL.
+	// attempt - номер попытки (0 - первая). При коллизии с уже существующим токеном вызывающий повторяет генерацию
+	// с увеличенным attempt, а генератор обязан вернуть другой токен (при необходимости - увеличив длину)
+	Generate(longURL string, attempt int) (string, error)
+}
+
+// New - создать генератор по названию стратегии.
+// alphabet используется только стратегией random, length - всеми, кроме words (минимальная длина токена)
+func New(strategy string, alphabet string, length int) (TokenGenerator, error) {
+	switch strategy {
+	case StrategyRandom, "":
+		return NewRandomGenerator(alphabet, length)
+	case StrategyCounter:
+		return NewCounterGenerator(0, length)
+	case StrategyHash:
+		return NewHashGenerator(length)
+	case StrategyWords:
+		return NewWordsGenerator(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownStrategy, strategy)
+	}
+}
+
+// validateLength - проверить длину токена
+func validateLength(length int) error {
+	if length < minLength || length > maxLength {
+		return errInvalidLength
+	}
+
+	return nil
+}
+
+// grownLength - длина токена с учётом номера попытки: каждые две неудачные попытки длина увеличивается на 1
+func grownLength(length int, attempt int) int {
+	return min(length+attempt/2, maxLength)
+}
+
+// encodeBase62 - закодировать число в base62
+func encodeBase62(n uint64) string {
+	if n == 0 {
+		return string(base62Alphabet[0])
+	}
+
+	var buf [11]byte // 62^11 > 2^64
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+
+	return string(buf[i:])
+}
+
+// decodeBase62 - раскодировать число из base62 (false - строка не является числом в base62 или не помещается в uint64)
+func decodeBase62(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base62Alphabet, s[i])
+		if digit < 0 || n > (math.MaxUint64-uint64(digit))/62 {
+			return 0, false
+		}
+		n = n*62 + uint64(digit)
+	}
+
+	return n, true
+}
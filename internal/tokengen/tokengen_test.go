@@ -0,0 +1,151 @@
+// Пакет tokengen_test содержит тесты стратегий генерации токенов
+package tokengen_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/JustScorpio/urlshortener/internal/tokengen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenPattern - токен должен быть безопасен для использования в пути URL
+var tokenPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// TestNew - проверка выбора стратегии по названию
+func TestNew(t *testing.T) {
+	for _, strategy := range []string{"", tokengen.StrategyRandom, tokengen.StrategyCounter, tokengen.StrategyHash, tokengen.StrategyWords} {
+		t.Run("strategy "+strategy, func(t *testing.T) {
+			generator, err := tokengen.New(strategy, tokengen.DefaultAlphabet, tokengen.DefaultLength)
+			require.NoError(t, err)
+
+			token, err := generator.Generate("https://example.com", 0)
+			require.NoError(t, err)
+			assert.Regexp(t, tokenPattern, token)
+		})
+	}
+
+	invalid := map[string]struct {
+		strategy string
+		alphabet string
+		length   int
+	}{
+		"unknown strategy":     {"uuid", tokengen.DefaultAlphabet, tokengen.DefaultLength},
+		"too short":            {tokengen.StrategyRandom, tokengen.DefaultAlphabet, 2},
+		"too long":             {tokengen.StrategyHash, tokengen.DefaultAlphabet, 65},
+		"unsafe alphabet":      {tokengen.StrategyRandom, "abc/?", tokengen.DefaultLength},
+		"single char alphabet": {tokengen.StrategyRandom, "a", tokengen.DefaultLength},
+	}
+	for name, tt := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := tokengen.New(tt.strategy, tt.alphabet, tt.length)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestRandomGenerator - проверка алфавита и роста длины при коллизиях
+func TestRandomGenerator(t *testing.T) {
+	generator, err := tokengen.NewRandomGenerator("0123456789", 6)
+	require.NoError(t, err)
+
+	token, err := generator.Generate("", 0)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9]{6}$`, token)
+
+	token, err = generator.Generate("", 4)
+	require.NoError(t, err)
+	assert.Len(t, token, 8)
+}
+
+// TestCounterGenerator - проверка уникальности и минимальной длины токенов счётчика
+func TestCounterGenerator(t *testing.T) {
+	generator, err := tokengen.NewCounterGenerator(0, 4)
+	require.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		token, err := generator.Generate("", i%3)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(token), 4)
+
+		_, duplicate := seen[token]
+		require.False(t, duplicate, "duplicate token %q", token)
+		seen[token] = struct{}{}
+	}
+}
+
+// TestCounterGeneratorSeed - проверка продолжения счёта с уже выданных токенов после перезапуска
+func TestCounterGeneratorSeed(t *testing.T) {
+	previous, err := tokengen.NewCounterGenerator(0, 4)
+	require.NoError(t, err)
+
+	issued := make(map[string]struct{})
+	var tokens []string
+	for i := 0; i < 100; i++ {
+		token, err := previous.Generate("", 0)
+		require.NoError(t, err)
+		issued[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+
+	// Токены не в формате счётчика (псевдонимы, дополненные лишними нулями) не учитываются
+	tokens = append(tokens, "my-alias", "0000000zz", "zz")
+
+	restarted, err := tokengen.NewCounterGenerator(0, 4)
+	require.NoError(t, err)
+	restarted.Seed(tokens)
+
+	for i := 0; i < 100; i++ {
+		token, err := restarted.Generate("", 0)
+		require.NoError(t, err)
+		assert.Len(t, token, 4)
+
+		_, collision := issued[token]
+		require.False(t, collision, "token %q was issued before restart", token)
+	}
+}
+
+// TestHashGenerator - проверка детерминированности и удлинения токена при коллизиях
+func TestHashGenerator(t *testing.T) {
+	generator, err := tokengen.NewHashGenerator(6)
+	require.NoError(t, err)
+
+	first, err := generator.Generate("https://example.com", 0)
+	require.NoError(t, err)
+	second, err := generator.Generate("https://example.com", 0)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, first, 6)
+
+	other, err := generator.Generate("https://example.org", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	retry, err := generator.Generate("https://example.com", 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, retry)
+	assert.Len(t, retry, 7)
+
+	// После исчерпания детерминированных попыток токены того же URL различаются
+	salted1, err := generator.Generate("https://example.com", 10)
+	require.NoError(t, err)
+	salted2, err := generator.Generate("https://example.com", 10)
+	require.NoError(t, err)
+	assert.NotEqual(t, salted1, salted2)
+}
+
+// TestWordsGenerator - проверка формата человекочитаемых токенов
+func TestWordsGenerator(t *testing.T) {
+	generator := tokengen.NewWordsGenerator()
+
+	for attempt := 0; attempt < 10; attempt++ {
+		t.Run(fmt.Sprintf("attempt %d", attempt), func(t *testing.T) {
+			token, err := generator.Generate("", attempt)
+			require.NoError(t, err)
+			assert.Regexp(t, `^[a-z]+-[a-z]+(-[0-9]+)?$`, token)
+		})
+	}
+}
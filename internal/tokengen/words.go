@@ -0,0 +1,68 @@
+package tokengen
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Словари для человекочитаемых токенов (только латиница в нижнем регистре)
+var (
+	adjectives = []string{
+		"amber", "bold", "brave", "bright", "calm", "clever", "cosmic", "crisp",
+		"daring", "eager", "early", "fancy", "fast", "fierce", "gentle", "giant",
+		"glad", "golden", "grand", "happy", "honest", "jolly", "keen", "kind",
+		"lively", "lucky", "mellow", "merry", "mighty", "modest", "noble", "proud",
+		"quick", "quiet", "rapid", "rare", "royal", "rustic", "shiny", "silent",
+		"silver", "simple", "sleek", "smart", "snowy", "solid", "sunny", "swift",
+		"tidy", "tiny", "urban", "vivid", "warm", "wild", "wise", "witty",
+		"young", "zesty", "azure", "crimson", "dusty", "frosty", "misty", "polar",
+	}
+	nouns = []string{
+		"badger", "beacon", "bear", "breeze", "brook", "canyon", "cedar", "comet",
+		"coral", "crane", "delta", "dune", "eagle", "falcon", "fern", "finch",
+		"fox", "glacier", "harbor", "hawk", "heron", "island", "jaguar", "lagoon",
+		"lark", "lemur", "lion", "lynx", "maple", "meadow", "meteor", "moose",
+		"orbit", "otter", "owl", "panda", "panther", "pebble", "pine", "planet",
+		"prairie", "raven", "reef", "river", "robin", "rocket", "salmon", "sparrow",
+		"spruce", "summit", "tiger", "thunder", "tulip", "valley", "walrus", "willow",
+		"wolf", "yak", "zebra", "acorn", "bison", "cobra", "dingo", "gecko",
+	}
+)
+
+// WordsGenerator - человекочитаемые токены вида "brave-otter"
+type WordsGenerator struct{}
+
+// NewWordsGenerator - инициализация генератора
+func NewWordsGenerator() *WordsGenerator {
+	return &WordsGenerator{}
+}
+
+// Generate - получить случайную пару "прилагательное-существительное".
+// Количество пар ограничено, поэтому после двух коллизий к паре добавляется случайное число,
+// разрядность которого растёт с каждой следующей парой попыток ("brave-otter-42", "brave-otter-907", ...)
+func (g *WordsGenerator) Generate(_ string, attempt int) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(adjectives[rand.IntN(len(adjectives))])
+	sb.WriteByte('-')
+	sb.WriteString(nouns[rand.IntN(len(nouns))])
+
+	if attempt >= 2 {
+		digits := min(2+(attempt-2)/2, 18)
+		lower := pow10(digits - 1)
+		sb.WriteByte('-')
+		sb.WriteString(strconv.FormatInt(lower+rand.Int64N(9*lower), 10))
+	}
+
+	return sb.String(), nil
+}
+
+// pow10 - 10 в степени n
+func pow10(n int) int64 {
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+
+	return result
+}